@@ -1,23 +1,42 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"database/sql"
 	"errors"
+	"os"
+	"os/signal"
+	"regexp"
+	"syscall"
 
+	"github.com/dsemenov12/loyalty-gofermart/internal/accrual"
+	"github.com/dsemenov12/loyalty-gofermart/internal/auth"
 	"github.com/dsemenov12/loyalty-gofermart/internal/config"
+	"github.com/dsemenov12/loyalty-gofermart/internal/cookies"
 	"github.com/dsemenov12/loyalty-gofermart/internal/storage/pg"
 	"github.com/dsemenov12/loyalty-gofermart/internal/handlers"
+	"github.com/dsemenov12/loyalty-gofermart/internal/health"
+	"github.com/dsemenov12/loyalty-gofermart/internal/metrics"
+	"github.com/dsemenov12/loyalty-gofermart/internal/tracing"
+	"github.com/dsemenov12/loyalty-gofermart/internal/validation"
+	"github.com/dsemenov12/loyalty-gofermart/internal/helpers/backoff"
 	"github.com/dsemenov12/loyalty-gofermart/internal/logger"
 	"github.com/dsemenov12/loyalty-gofermart/internal/middlewares/loggerhandler"
 	"github.com/dsemenov12/loyalty-gofermart/internal/middlewares/gziphandler"
 	"github.com/dsemenov12/loyalty-gofermart/internal/middlewares/authhandler"
+	"github.com/dsemenov12/loyalty-gofermart/internal/middlewares/csrfhandler"
+	"github.com/dsemenov12/loyalty-gofermart/internal/middlewares/requestidhandler"
+	"github.com/dsemenov12/loyalty-gofermart/internal/middlewares/metricshandler"
+	"github.com/dsemenov12/loyalty-gofermart/internal/middlewares/tracinghandler"
+	"github.com/dsemenov12/loyalty-gofermart/internal/notify"
 	"go.uber.org/zap"
 	"github.com/go-chi/chi/v5"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 )
 
@@ -32,6 +51,10 @@ func run() error {
 		return errors.New("empty database DSN")
 	}
 
+	// Контекст отменяется по SIGINT или SIGTERM, после чего начинается остановка сервиса
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Подключение к БД
 	conn, err := sql.Open("pgx", config.FlagDatabaseURI)
 	if err != nil {
@@ -44,35 +67,262 @@ func run() error {
 		return err
 	}
 
-	storage := pg.NewStorage(conn)
+	// Проверки готовности обращаются к БД напрямую, остальные вызовы хранилища замеряются и трассируются
+	db := pg.NewStorage(conn)
+	storage := tracing.NewStorage(metrics.NewStorage(db))
 	app := handlers.NewApp(storage)
 
+	// С одного адреса могут входить многие пользователи, поэтому порог по адресу выше
+	app.SetLoginThrottle(handlers.LoginThrottle{
+		Login:             backoff.Policy{Threshold: config.FlagLoginMaxAttempts, Base: config.FlagLoginLockout, Max: config.FlagLoginMaxLockout},
+		IP:                backoff.Policy{Threshold: 4 * config.FlagLoginMaxAttempts, Base: config.FlagLoginLockout, Max: config.FlagLoginMaxLockout},
+		Window:            config.FlagLoginAttemptWindow,
+		TrustProxyHeaders: config.FlagTrustProxyHeaders,
+	})
+
+	// Судьба остатка баланса при удалении учётной записи
+	if err = app.SetBalancePolicy(config.FlagAccountBalancePolicy); err != nil {
+		return err
+	}
+
 	if err = logger.Initialize(config.FlagLogLevel); err != nil {
         return err
     }
-	logger.Log.Info("Running server", zap.String("address", config.FlagRunAddr))
+
+	// Трассировка запросов, вызовов хранилища и обращений к системе начислений
+	shutdownTracing, err := tracing.Initialize(ctx, config.FlagTracingExporter, config.FlagTracingSampleRatio)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Накопленные спаны выгружаются уже после отмены основного контекста
+		flushCtx, cancel := context.WithTimeout(context.Background(), config.FlagShutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			logger.Log.Error("tracing shutdown", zap.Error(err))
+		}
+	}()
+
+	// Ключи подписи JWT
+	if err = auth.Initialize(config.FlagJWTSecret, config.FlagJWTKeysFile); err != nil {
+		return err
+	}
+	if config.FlagJWTSecret == "" && config.FlagJWTKeysFile == "" {
+		logger.Log.Warn("JWT secret is not configured, using a random key: tokens will not survive restart")
+	}
+
+	// Ключ шифрования секретов TOTP в БД
+	if err = auth.InitializeTOTPKey(config.FlagTOTPEncryptionKey); err != nil {
+		return err
+	}
+	if !auth.TOTPKeyConfigured() {
+		logger.Log.Warn("TOTP encryption key is not configured: two-factor setup is disabled")
+	}
+
+	// Клиент системы начислений нужен воркерам и проверке готовности
+	accrualClient := accrual.NewClient(config.FlagAccrualSystemAddress)
+
+	// Политика проверки логина и пароля при регистрации
+	if err = initValidation(); err != nil {
+		return err
+	}
+
+	// Атрибуты куки авторизации и проверка CSRF
+	err = cookies.Initialize(config.FlagCookieDomain, config.FlagCookieSecure, config.FlagCookieSameSite, config.FlagCSRFProtection)
+	if err != nil {
+		return err
+	}
+	if !config.FlagCSRFProtection {
+		logger.Log.Warn("CSRF protection is disabled: cookie-authenticated requests are not checked for X-CSRF-Token")
+	}
+	if !config.FlagCookieSecure {
+		logger.Log.Warn("auth cookies are sent without the Secure attribute: enable it for any deployment served over HTTPS")
+	}
+
+	// Токены сброса пароля доставляются только через сервис уведомлений
+	if config.FlagNotifyWebhookURL != "" {
+		app.SetSender(notify.NewWebhookSender(config.FlagNotifyWebhookURL, config.FlagNotifyWebhookToken))
+	} else {
+		logger.Log.Warn("notification service is not configured: password reset is disabled")
+	}
+
+	// Отозванные сессии отклоняются при проверке токена
+	authhandler.Initialize(storage)
+
+	checker, err := newHealthChecker(db, accrualClient)
+	if err != nil {
+		return err
+	}
+
+	// Входящий контекст трассы принимается только от доверенного прокси
+	tracinghandler.Initialize(config.FlagTracingTrustIncoming)
 
 	router := chi.NewRouter()
+	router.Use(tracinghandler.TracingHandle)
+	router.Use(metricshandler.MetricsHandle)
+
+	// Проверки оркестратора и сбор метрик не пишутся в лог запросов
+	router.Get("/healthz", health.Liveness)
+	router.Get("/readyz", checker.Readiness)
+	router.Handle("/metrics", metrics.Handler())
 
 	router.Post("/api/user/register", loggerhandler.RequestLogger(app.UserRegister))
 	router.Post("/api/user/login", loggerhandler.RequestLogger(app.UserLogin))
-	router.Post("/api/user/orders", loggerhandler.RequestLogger(authhandler.AuthHandle(app.UserUploadOrder)))
+	router.Post("/api/user/login/2fa", loggerhandler.RequestLogger(app.UserLoginTwoFactor))
+	router.Post("/api/user/token/refresh", loggerhandler.RequestLogger(csrfhandler.CSRFHandle(app.RefreshToken)))
+	router.Post("/api/user/logout", loggerhandler.RequestLogger(csrfhandler.CSRFHandle(authhandler.AuthHandle(app.UserLogout))))
+	router.Post("/api/user/password", loggerhandler.RequestLogger(csrfhandler.CSRFHandle(authhandler.AuthHandle(app.ChangePassword))))
+	if app.PasswordResetEnabled() {
+		router.Post("/api/user/password/reset/request", loggerhandler.RequestLogger(app.RequestPasswordReset))
+		router.Post("/api/user/password/reset/confirm", loggerhandler.RequestLogger(app.ConfirmPasswordReset))
+	}
+	router.Delete("/api/user", loggerhandler.RequestLogger(csrfhandler.CSRFHandle(authhandler.AuthHandle(app.DeleteAccount))))
+	router.Get("/api/user/export", loggerhandler.RequestLogger(authhandler.AuthHandle(app.ExportUserData)))
+	router.Post("/api/user/2fa/setup", loggerhandler.RequestLogger(csrfhandler.CSRFHandle(authhandler.AuthHandle(app.SetupTwoFactor))))
+	router.Post("/api/user/2fa/verify", loggerhandler.RequestLogger(csrfhandler.CSRFHandle(authhandler.AuthHandle(app.VerifyTwoFactor))))
+	router.Post("/api/user/2fa/disable", loggerhandler.RequestLogger(csrfhandler.CSRFHandle(authhandler.AuthHandle(app.DisableTwoFactor))))
+	router.Post("/api/user/orders", loggerhandler.RequestLogger(csrfhandler.CSRFHandle(authhandler.AuthHandle(app.UserUploadOrder))))
 	router.Get("/api/user/orders", loggerhandler.RequestLogger(authhandler.AuthHandle(app.UserGetOrders)))
 	router.Get("/api/user/balance", loggerhandler.RequestLogger(authhandler.AuthHandle(app.GetUserBalance)))
-	router.Post("/api/user/balance/withdraw", loggerhandler.RequestLogger(authhandler.AuthHandle(app.WithdrawUserBalance)))
+	router.Get("/api/user/balance/history", loggerhandler.RequestLogger(authhandler.AuthHandle(app.GetUserBalanceHistory)))
+	router.Post("/api/user/balance/withdraw", loggerhandler.RequestLogger(csrfhandler.CSRFHandle(authhandler.AuthHandle(app.WithdrawUserBalance))))
 	router.Get("/api/user/withdrawals", loggerhandler.RequestLogger(authhandler.AuthHandle(app.GetUserWithdrawals)))
 
-	err = http.ListenAndServe(
-		config.FlagRunAddr,
-		gziphandler.GzipHandle(router),
-	)
-    if err != nil {
-        return err
-    }
+	// API администратора доступно только с ролью admin. CSRF-токен проверяется только у изменяющих запросов
+	admin := func(handlerFunc http.HandlerFunc) http.HandlerFunc {
+		return loggerhandler.RequestLogger(csrfhandler.CSRFHandle(authhandler.AuthHandle(authhandler.RoleHandle(auth.RoleAdmin, handlerFunc))))
+	}
+	router.Get("/api/admin/users", admin(app.AdminFindUser))
+	router.Get("/api/admin/users/{userID}", admin(app.AdminGetUser))
+	router.Get("/api/admin/users/{userID}/orders", admin(app.AdminGetUserOrders))
+	router.Get("/api/admin/users/{userID}/balance", admin(app.AdminGetUserBalance))
+	router.Get("/api/admin/users/{userID}/balance/adjustments", admin(app.AdminGetBalanceAdjustments))
+	router.Post("/api/admin/users/{userID}/balance/adjustments", admin(app.AdminAdjustBalance))
+	router.Get("/api/admin/orders/{number}", admin(app.AdminGetOrder))
 
+	server := &http.Server{
+		Addr:    config.FlagRunAddr,
+		Handler: requestidhandler.RequestIDHandle(gziphandler.GzipHandle(router)),
+	}
+
+	logger.Log.Info("Running server", zap.String("address", config.FlagRunAddr))
+
+	// Воркеры запускаются после всей инициализации, которая может завершиться ошибкой,
+	// иначе при выходе из run они остались бы работать с закрытым соединением с БД
+	worker := accrual.NewWorker(storage, accrualClient, config.FlagAccrualWorkers, config.FlagAccrualBatchSize,
+		config.FlagAccrualPollInterval, config.FlagAccrualMaxBackoff)
+	workerDone := make(chan struct{})
+	go func() {
+		worker.Run(ctx)
+		close(workerDone)
+	}()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serveErr:
+		// Сервер не запустился: воркеры останавливаются вместе с ним
+		stop()
+		<-workerDone
+		return err
+	case <-ctx.Done():
+	}
+
+	return shutdown(server, workerDone)
+}
+
+// Остановка сервиса: новые соединения не принимаются, текущие запросы и заказы воркеров
+// завершаются в пределах тайм-аута. Соединения с БД закрываются после возврата из run
+func shutdown(server *http.Server, workerDone <-chan struct{}) error {
+	logger.Log.Info("Shutting down server", zap.Duration("timeout", config.FlagShutdownTimeout))
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.FlagShutdownTimeout)
+	defer cancel()
+
+	err := server.Shutdown(ctx)
+	if err != nil {
+		logger.Log.Error("HTTP server shutdown", zap.Error(err))
+	}
+
+	select {
+	case <-workerDone:
+	case <-ctx.Done():
+		logger.Log.Warn("accrual workers did not finish before shutdown timeout")
+	}
+
+	logger.Log.Info("Server stopped")
+	return err
+}
+
+// Настройка политики проверки учётных данных
+func initValidation() error {
+	loginPattern, err := regexp.Compile(config.FlagLoginPattern)
+	if err != nil {
+		return fmt.Errorf("login pattern: %w", err)
+	}
+
+	policy := validation.DefaultPolicy
+	policy.LoginPattern = loginPattern
+	policy.PasswordMinLength = config.FlagPasswordMinLength
+	policy.PasswordCharClasses = config.FlagPasswordCharClasses
+	if config.FlagBreachedPasswordsFile != "" {
+		if policy.Breached, err = validation.LoadBreachedPasswords(config.FlagBreachedPasswordsFile); err != nil {
+			return err
+		}
+	}
+
+	validation.Initialize(policy)
 	return nil
 }
 
+// Расположение файлов миграций
+const migrationsURL = "file://./db/migrations"
+
+// Версия последней миграции среди файлов миграций
+func latestMigrationVersion() (uint, error) {
+	src, err := source.Open(migrationsURL)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	version, err := src.First()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, os.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
+
+// Проверки зависимостей для /readyz
+func newHealthChecker(storage *pg.StorageDB, accrualClient *accrual.Client) (*health.Checker, error) {
+	migrationVersion, err := latestMigrationVersion()
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	checker := health.NewChecker(config.FlagHealthCheckTimeout)
+	checker.Add("postgres", true, storage.Ping)
+	checker.Add("migrations", true, func(ctx context.Context) error {
+		return storage.CheckMigrations(ctx, migrationVersion)
+	})
+	// Без системы начислений заказы принимаются, но не обрабатываются
+	checker.Add("accrual", config.FlagReadinessRequireAccrual, accrualClient.Ping)
+
+	return checker, nil
+}
+
 // Запуск миграций
 func upMigrations(conn *sql.DB) error {
 	driver, err := postgres.WithInstance(conn, &postgres.Config{})
@@ -80,14 +330,17 @@ func upMigrations(conn *sql.DB) error {
 		return err
 	}
 	m, err := migrate.NewWithDatabaseInstance(
-		"file://./db/migrations",
+		migrationsURL,
 		"postgres",
 		driver,
 	)
 	if err != nil {
 		return err
 	}
-	m.Up()
+	// Ошибка миграции останавливает запуск: сервис не должен работать с частично применённой схемой
+	if err = m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("apply migrations: %w", err)
+	}
 
 	return nil
 }
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS ledger;
+DROP FUNCTION IF EXISTS ledger_immutable;
+DROP SEQUENCE IF EXISTS ledger_transaction_seq;
\ No newline at end of file
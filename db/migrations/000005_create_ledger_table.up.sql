@@ -0,0 +1,67 @@
+CREATE SEQUENCE ledger_transaction_seq;
+
+CREATE TABLE ledger (
+    id BIGSERIAL PRIMARY KEY,
+    transaction_id BIGINT NOT NULL,
+    user_id INT NOT NULL,
+    account VARCHAR(32) NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL,
+    reference VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_ledger_user_account ON ledger (user_id, account);
+CREATE INDEX idx_ledger_transaction ON ledger (transaction_id);
+
+-- Проводки нельзя ни изменить, ни удалить: ошибочная проводка исправляется новой
+CREATE FUNCTION ledger_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'ledger postings are immutable';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER ledger_no_update BEFORE UPDATE OR DELETE ON ledger
+    FOR EACH ROW EXECUTE FUNCTION ledger_immutable();
+
+CREATE TRIGGER ledger_no_truncate BEFORE TRUNCATE ON ledger
+    FOR EACH STATEMENT EXECUTE FUNCTION ledger_immutable();
+
+-- Перенос начислений по обработанным заказам
+WITH src AS (
+    SELECT nextval('ledger_transaction_seq') AS transaction_id, user_id, number, accrual, updated_at
+    FROM orders
+    WHERE status = 'PROCESSED' AND accrual > 0
+)
+INSERT INTO ledger (transaction_id, user_id, account, kind, amount, reference, created_at)
+SELECT transaction_id, user_id, 'current', 'ACCRUAL', accrual, number, updated_at FROM src
+UNION ALL
+SELECT transaction_id, user_id, 'accrual', 'ACCRUAL', -accrual, number, updated_at FROM src;
+
+-- Перенос списаний
+WITH src AS (
+    SELECT nextval('ledger_transaction_seq') AS transaction_id, user_id, order_number, sum, created_at
+    FROM withdraw
+)
+INSERT INTO ledger (transaction_id, user_id, account, kind, amount, reference, created_at)
+SELECT transaction_id, user_id, 'current', 'WITHDRAWAL', -sum, order_number, created_at FROM src
+UNION ALL
+SELECT transaction_id, user_id, 'withdrawn', 'WITHDRAWAL', sum, order_number, created_at FROM src;
+
+-- Сверка с таблицей balance: расхождения фиксируются корректирующими проводками
+WITH src AS (
+    SELECT nextval('ledger_transaction_seq') AS transaction_id, b.user_id,
+        b.current - COALESCE(SUM(l.amount) FILTER (WHERE l.account = 'current'), 0) AS current_diff,
+        b.withdrawn - COALESCE(SUM(l.amount) FILTER (WHERE l.account = 'withdrawn'), 0) AS withdrawn_diff
+    FROM balance b
+    LEFT JOIN ledger l ON l.user_id = b.user_id
+    GROUP BY b.user_id, b.current, b.withdrawn
+)
+INSERT INTO ledger (transaction_id, user_id, account, kind, amount, reference)
+SELECT transaction_id, user_id, 'current', 'ADJUSTMENT', current_diff, 'migration' FROM src WHERE current_diff <> 0
+UNION ALL
+SELECT transaction_id, user_id, 'withdrawn', 'ADJUSTMENT', withdrawn_diff, 'migration' FROM src WHERE withdrawn_diff <> 0
+UNION ALL
+SELECT transaction_id, user_id, 'adjustment', 'ADJUSTMENT', -(current_diff + withdrawn_diff), 'migration' FROM src
+    WHERE current_diff <> 0 OR withdrawn_diff <> 0;
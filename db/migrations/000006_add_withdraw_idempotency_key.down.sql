@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_withdraw_idempotency_key;
+ALTER TABLE withdraw DROP COLUMN IF EXISTS idempotency_key;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE withdraw ADD COLUMN idempotency_key VARCHAR(255);
+
+CREATE UNIQUE INDEX idx_withdraw_idempotency_key ON withdraw (user_id, idempotency_key)
+    WHERE idempotency_key IS NOT NULL;
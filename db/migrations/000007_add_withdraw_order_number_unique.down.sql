@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_withdraw_order_number;
\ No newline at end of file
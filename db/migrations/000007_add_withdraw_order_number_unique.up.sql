@@ -0,0 +1,25 @@
+-- До этой миграции номер заказа мог использоваться для списания несколько раз.
+-- Такие списания нельзя объединить или удалить автоматически, поэтому миграция
+-- останавливается и перечисляет номера: дубликаты нужно разобрать вручную
+DO $$
+DECLARE
+    duplicates_count INT;
+    duplicates TEXT;
+BEGIN
+    SELECT COUNT(*), string_agg(order_number, ', ' ORDER BY order_number) FILTER (WHERE rn <= 20)
+    INTO duplicates_count, duplicates
+    FROM (
+        SELECT order_number, ROW_NUMBER() OVER (ORDER BY order_number) AS rn
+        FROM withdraw
+        GROUP BY order_number
+        HAVING COUNT(*) > 1
+    ) d;
+
+    IF duplicates_count > 0 THEN
+        RAISE EXCEPTION 'withdraw has % order numbers used more than once: %', duplicates_count, duplicates
+            USING HINT = 'Resolve duplicate withdrawals manually, then run migrate force 6 and restart the service';
+    END IF;
+END;
+$$;
+
+CREATE UNIQUE INDEX idx_withdraw_order_number ON withdraw (order_number);
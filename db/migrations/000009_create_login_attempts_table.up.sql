@@ -0,0 +1,7 @@
+-- Неудачные попытки входа. Ключ имеет вид login:<логин> или ip:<адрес клиента>
+CREATE TABLE login_attempts (
+    key VARCHAR(320) PRIMARY KEY,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until TIMESTAMP
+);
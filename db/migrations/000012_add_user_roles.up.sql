@@ -0,0 +1,4 @@
+-- Роль пользователя попадает в access-токен и определяет доступ к /api/admin
+ALTER TABLE users
+    ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'user',
+    ADD CONSTRAINT chk_users_role CHECK (role IN ('user', 'admin'));
@@ -0,0 +1,18 @@
+-- Журнал ручных корректировок баланса. Оператор не связан внешним ключом,
+-- чтобы запись сохранялась после удаления его учётной записи. Корректировать собственный баланс нельзя
+CREATE TABLE balance_adjustments (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    operator_id INT NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL CHECK (amount <> 0),
+    reason VARCHAR(255) NOT NULL CHECK (reason <> ''),
+    idempotency_key VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    CONSTRAINT chk_operator_not_user CHECK (operator_id <> user_id),
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_balance_adjustments_user ON balance_adjustments (user_id, created_at);
+
+CREATE UNIQUE INDEX idx_balance_adjustments_idempotency_key ON balance_adjustments (operator_id, idempotency_key)
+    WHERE idempotency_key IS NOT NULL;
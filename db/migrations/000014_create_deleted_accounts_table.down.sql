@@ -0,0 +1,29 @@
+DROP TABLE IF EXISTS deleted_accounts;
+
+ALTER TABLE balance_adjustments
+    DROP CONSTRAINT fk_user,
+    ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE withdraw
+    DROP CONSTRAINT fk_user,
+    ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE orders
+    DROP CONSTRAINT fk_user,
+    ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE balance
+    DROP CONSTRAINT fk_user,
+    ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE ledger
+    DROP CONSTRAINT fk_user,
+    ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+-- Проводки удалённых учётных записей удалить нельзя, поэтому строки пользователей остаются
+-- с логином-заглушкой и пустым паролем, по которому войти невозможно
+UPDATE users SET login = 'deleted-' || id WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE users
+    ALTER COLUMN login SET NOT NULL,
+    DROP COLUMN deleted_at;
@@ -0,0 +1,35 @@
+-- Удалённая учётная запись остаётся строкой без персональных данных: на неё ссылаются проводки
+-- главной книги, заказы и списания, а их номера нельзя использовать повторно
+ALTER TABLE users
+    ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE,
+    ALTER COLUMN login DROP NOT NULL;
+
+-- Финансовые данные не удаляются вместе с пользователем
+ALTER TABLE ledger
+    DROP CONSTRAINT fk_user,
+    ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE RESTRICT;
+
+ALTER TABLE balance
+    DROP CONSTRAINT fk_user,
+    ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE RESTRICT;
+
+ALTER TABLE orders
+    DROP CONSTRAINT fk_user,
+    ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE RESTRICT;
+
+ALTER TABLE withdraw
+    DROP CONSTRAINT fk_user,
+    ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE RESTRICT;
+
+ALTER TABLE balance_adjustments
+    DROP CONSTRAINT fk_user,
+    ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE RESTRICT;
+
+-- Остаток баланса удалённых учётных записей нужен для сверки главной книги
+CREATE TABLE deleted_accounts (
+    user_id INT PRIMARY KEY,
+    balance_policy VARCHAR(16) NOT NULL CHECK (balance_policy IN ('forfeit', 'archive')),
+    current DECIMAL(10, 2) NOT NULL,
+    withdrawn DECIMAL(10, 2) NOT NULL,
+    deleted_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_orders_next_poll;
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS next_poll_at,
+    DROP COLUMN IF EXISTS poll_attempts;
@@ -0,0 +1,8 @@
+-- Расписание опроса системы начислений: заказ без окончательного статуса запрашивается повторно
+-- не раньше next_poll_at, интервал растёт с числом безрезультатных опросов
+ALTER TABLE orders
+    ADD COLUMN poll_attempts INT NOT NULL DEFAULT 0,
+    ADD COLUMN next_poll_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX idx_orders_next_poll ON orders (next_poll_at)
+    WHERE status IN ('NEW', 'REGISTERED', 'PROCESSING');
@@ -1,55 +1,89 @@
 package accrual
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/dsemenov12/loyalty-gofermart/internal/logger"
+	"github.com/dsemenov12/loyalty-gofermart/internal/metrics"
 	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.uber.org/zap"
 )
 
 type AccrualClient interface {
-	GetAccrualInfo(orderNumber string) (*models.AccrualInfo, error)
+	GetAccrualInfo(ctx context.Context, orderNumber string) (*models.AccrualInfo, error)
 }
 
 type Client struct {
 	httpClient *http.Client
 	baseURL    string
+	limiter    *limiter
 }
 
 func NewClient(baseURL string) *Client {
 	return &Client{
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
-			Transport: &http.Transport{
+			// Клиентский спан на каждый запрос и передача контекста трассы в заголовке traceparent
+			Transport: otelhttp.NewTransport(&http.Transport{
 				MaxIdleConns: 100,
 				IdleConnTimeout: 10 * time.Second,
 				DisableKeepAlives: false,
-			},
+			}),
 		},
 		baseURL: baseURL,
+		limiter: &limiter{},
 	}
 }
 
+// Проверка доступности системы начислений. Любой ответ, кроме 5xx, означает, что она доступна.
+// Ограничение частоты запросов не учитывается
+func (c *Client) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("accrual system responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
 // Получает статус заказа и количество начисленных баллов из стороннего сервиса
-func (c *Client) GetAccrualInfo(orderNumber string) (*models.AccrualInfo, error) {
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/api/orders/%s", c.baseURL, orderNumber), nil)
+func (c *Client) GetAccrualInfo(ctx context.Context, orderNumber string) (*models.AccrualInfo, error) {
+	// Ожидание, если система начислений попросила сделать паузу
+	if err := c.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/api/orders/%s", c.baseURL, orderNumber), nil)
 	if err != nil {
 		return nil, err
 	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
+		metrics.AccrualRequests.WithLabelValues(metrics.OutcomeError).Inc()
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	logger.Log.Info("Order processing", zap.String("status", strconv.Itoa(resp.StatusCode)))
+	metrics.AccrualRequests.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
+	// Запрос выполняется на каждый опрос заказа, поэтому пишется только на уровне Debug
+	logger.Log.Debug("accrual request", zap.String("order", orderNumber), zap.Int("status", resp.StatusCode))
 
 	// Обработка кодов ответа
 	switch resp.StatusCode {
@@ -60,10 +94,20 @@ func (c *Client) GetAccrualInfo(orderNumber string) (*models.AccrualInfo, error)
 		}
 		return &accrual, nil
 	case http.StatusNoContent:
-		return nil, errors.New("order not found")
+		return nil, ErrOrderNotRegistered
 	case http.StatusTooManyRequests:
-		return nil, errors.New("too many requests")
+		// Приостанавливаем все исходящие запросы и подстраиваемся под лимит сервиса
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		c.limiter.Pause(retryAfter)
+		fields := []zap.Field{zap.Duration("retry_after", retryAfter)}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if perMinute, ok := parseRateLimit(body); ok {
+			c.limiter.SetRate(perMinute)
+			fields = append(fields, zap.Int("rate_per_minute", perMinute))
+		}
+		logger.Log.Warn("accrual system rate limit exceeded", fields...)
+		return nil, &RateLimitError{RetryAfter: retryAfter}
 	default:
-		return nil, fmt.Errorf("unexpected response code: %d", resp.StatusCode)
+		return nil, &StatusError{StatusCode: resp.StatusCode}
 	}
 }
\ No newline at end of file
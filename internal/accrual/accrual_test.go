@@ -1,13 +1,22 @@
 package accrual
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
 	"testing"
+	"github.com/dsemenov12/loyalty-gofermart/internal/metrics"
 	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/money"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 // Тестирование метода GetAccrualInfo
@@ -19,13 +28,14 @@ func TestClient_GetAccrualInfo(t *testing.T) {
 		responseBody   interface{}
 		expectedResult *models.AccrualInfo
 		expectedError  error
+		expectedIs     error
 	}{
 		{
 			name:         "valid response",
 			orderNumber:  "123456",
 			responseCode: http.StatusOK,
-			responseBody: models.AccrualInfo{OrderNumber: "123456", Status: "PROCESSED", Accrual: 100.0},
-			expectedResult: &models.AccrualInfo{OrderNumber: "123456", Status: "PROCESSED", Accrual: 100.0},
+			responseBody: models.AccrualInfo{OrderNumber: "123456", Status: "PROCESSED", Accrual: money.MustParse("100")},
+			expectedResult: &models.AccrualInfo{OrderNumber: "123456", Status: "PROCESSED", Accrual: money.MustParse("100")},
 			expectedError:  nil,
 		},
 		{
@@ -35,6 +45,7 @@ func TestClient_GetAccrualInfo(t *testing.T) {
 			responseBody: nil,
 			expectedResult: nil,
 			expectedError:  errors.New("order not found"),
+			expectedIs:     ErrOrderNotRegistered,
 		},
 		{
 			name:         "too many requests",
@@ -43,6 +54,7 @@ func TestClient_GetAccrualInfo(t *testing.T) {
 			responseBody: nil,
 			expectedResult: nil,
 			expectedError:  errors.New("too many requests"),
+			expectedIs:     ErrTooManyRequests,
 		},
 		{
 			name:         "unexpected status code",
@@ -64,15 +76,63 @@ func TestClient_GetAccrualInfo(t *testing.T) {
 			}))
 			defer ts.Close()
 
+			outcome := metrics.AccrualRequests.WithLabelValues(strconv.Itoa(tt.responseCode))
+			before := testutil.ToFloat64(outcome)
+
 			client := NewClient(ts.URL)
-			result, err := client.GetAccrualInfo(tt.orderNumber)
+			result, err := client.GetAccrualInfo(context.Background(), tt.orderNumber)
 
 			assert.Equal(t, tt.expectedResult, result)
+			assert.Equal(t, before+1, testutil.ToFloat64(outcome))
 			if tt.expectedError != nil {
 				assert.EqualError(t, err, tt.expectedError.Error())
+				if tt.expectedIs != nil {
+					assert.ErrorIs(t, err, tt.expectedIs)
+				}
 			} else {
 				assert.NoError(t, err)
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+// Тестирование проверки доступности системы начислений
+func TestClient_Ping(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusNotFound)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer ts.Close()
+
+	client := NewClient(ts.URL)
+	assert.NoError(t, client.Ping(context.Background()))
+
+	status.Store(http.StatusBadGateway)
+	assert.Error(t, client.Ping(context.Background()))
+
+	ts.Close()
+	assert.Error(t, client.Ping(context.Background()))
+}
+
+// Тестирование передачи контекста трассы в систему начислений
+func TestClient_GetAccrualInfo_TraceContext(t *testing.T) {
+	previous := otel.GetTextMapPropagator()
+	t.Cleanup(func() { otel.SetTextMapPropagator(previous) })
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var traceparent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "upload")
+	defer span.End()
+
+	client := NewClient(ts.URL)
+	_, err := client.GetAccrualInfo(ctx, "2377225624")
+
+	assert.ErrorIs(t, err, ErrOrderNotRegistered)
+	assert.Contains(t, traceparent, span.SpanContext().TraceID().String())
+}
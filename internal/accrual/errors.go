@@ -0,0 +1,36 @@
+package accrual
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// Заказ не зарегистрирован в системе начислений (ответ 204)
+	ErrOrderNotRegistered = errors.New("order not found")
+	// Превышено количество запросов к системе начислений (ответ 429)
+	ErrTooManyRequests = errors.New("too many requests")
+)
+
+// Ошибка превышения лимита запросов с паузой, которую запросила система начислений
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return ErrTooManyRequests.Error()
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrTooManyRequests
+}
+
+// Неожиданный код ответа системы начислений
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected response code: %d", e.StatusCode)
+}
@@ -0,0 +1,113 @@
+package accrual
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Пауза по умолчанию, если система начислений не прислала Retry-After
+const defaultRetryAfter = time.Minute
+
+// Текст ответа системы начислений при превышении лимита запросов
+var rateLimitBody = regexp.MustCompile(`No more than (\d+) requests per minute allowed`)
+
+// Общий для всех исходящих запросов ограничитель частоты обращений к системе начислений
+type limiter struct {
+	mu          sync.Mutex
+	interval    time.Duration // минимальный промежуток между запросами, 0 — без ограничения
+	next        time.Time     // момент, раньше которого нельзя отправить следующий запрос
+	pausedUntil time.Time     // момент окончания паузы после ответа 429
+}
+
+// Ожидает возможности отправить запрос
+func (l *limiter) Wait(ctx context.Context) error {
+	for {
+		delay := l.reserve()
+		if delay <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Занимает слот для запроса либо возвращает время, которое нужно подождать
+func (l *limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+	if now.Before(l.next) {
+		return l.next.Sub(now)
+	}
+	l.next = now.Add(l.interval)
+
+	return 0
+}
+
+// Приостанавливает все запросы на заданное время
+func (l *limiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until := time.Now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// Устанавливает допустимое количество запросов в минуту
+func (l *limiter) SetRate(perMinute int) {
+	if perMinute <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.interval = time.Minute / time.Duration(perMinute)
+}
+
+// Разбирает заголовок Retry-After: количество секунд либо HTTP-дата
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return defaultRetryAfter
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := date.Sub(now); d > 0 {
+			return d
+		}
+		return 0
+	}
+
+	return defaultRetryAfter
+}
+
+// Извлекает лимит запросов в минуту из тела ответа 429
+func parseRateLimit(body []byte) (int, bool) {
+	match := rateLimitBody.FindSubmatch(body)
+	if match == nil {
+		return 0, false
+	}
+	perMinute, err := strconv.Atoi(string(match[1]))
+	if err != nil || perMinute <= 0 {
+		return 0, false
+	}
+
+	return perMinute, true
+}
@@ -0,0 +1,91 @@
+package accrual
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Тестирование разбора заголовка Retry-After
+func Test_parseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 8, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{name: "seconds", value: "60", want: time.Minute},
+		{name: "http date", value: now.Add(30 * time.Second).Format(http.TimeFormat), want: 30 * time.Second},
+		{name: "date in the past", value: now.Add(-time.Minute).Format(http.TimeFormat), want: 0},
+		{name: "empty header", value: "", want: defaultRetryAfter},
+		{name: "invalid header", value: "soon", want: defaultRetryAfter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseRetryAfter(tt.value, now))
+		})
+	}
+}
+
+// Тестирование разбора лимита запросов из тела ответа
+func Test_parseRateLimit(t *testing.T) {
+	perMinute, ok := parseRateLimit([]byte("No more than 60 requests per minute allowed"))
+	assert.True(t, ok)
+	assert.Equal(t, 60, perMinute)
+
+	_, ok = parseRateLimit([]byte("Too Many Requests"))
+	assert.False(t, ok)
+}
+
+// Тестирование паузы и ограничения частоты запросов
+func Test_limiter(t *testing.T) {
+	l := &limiter{}
+	assert.NoError(t, l.Wait(context.Background()))
+
+	// Во время паузы запросы не отправляются
+	l.Pause(time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, l.Wait(ctx), context.DeadlineExceeded)
+
+	// Лимит задаёт минимальный промежуток между запросами
+	l = &limiter{}
+	l.SetRate(600)
+	start := time.Now()
+	assert.NoError(t, l.Wait(context.Background()))
+	assert.NoError(t, l.Wait(context.Background()))
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+}
+
+// Тестирование общей паузы клиента после ответа 429
+func TestClient_GetAccrualInfo_TooManyRequests(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte("No more than 60 requests per minute allowed"))
+	}))
+	defer ts.Close()
+
+	client := NewClient(ts.URL)
+	_, err := client.GetAccrualInfo(context.Background(), "12345678903")
+	assert.ErrorIs(t, err, ErrTooManyRequests)
+	var rateLimitErr *RateLimitError
+	assert.ErrorAs(t, err, &rateLimitErr)
+	assert.Equal(t, time.Minute, rateLimitErr.RetryAfter)
+
+	// Повторный запрос не уходит в систему начислений до окончания паузы
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.GetAccrualInfo(ctx, "12345678903")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, requests)
+	assert.Equal(t, time.Second, client.limiter.interval)
+}
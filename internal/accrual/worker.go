@@ -0,0 +1,193 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/helpers/backoff"
+	"github.com/dsemenov12/loyalty-gofermart/internal/logger"
+	"github.com/dsemenov12/loyalty-gofermart/internal/metrics"
+	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/money"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage"
+	"github.com/dsemenov12/loyalty-gofermart/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// Время на сохранение полученного статуса заказа, в том числе после начала остановки сервиса
+const storeTimeout = 10 * time.Second
+
+// Пул воркеров, опрашивающих систему начислений по заказам в неокончательных статусах.
+// Заказы берутся из БД при запуске и далее с заданным интервалом,
+// поэтому после перезапуска сервиса ни один заказ не остаётся необработанным.
+// За цикл выбирается не больше batchSize заказов, срок опроса которых наступил; заказ без
+// окончательного статуса откладывается, и с каждым безрезультатным опросом задержка удваивается
+type Worker struct {
+	storage   storage.Storage
+	client    AccrualClient
+	workers   int
+	batchSize int
+	interval  time.Duration
+	backoff   backoff.Policy
+
+	mu       sync.Mutex
+	inFlight map[string]struct{}
+}
+
+func NewWorker(storage storage.Storage, client AccrualClient, workers, batchSize int, interval, maxBackoff time.Duration) *Worker {
+	if workers < 1 {
+		workers = 1
+	}
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	return &Worker{
+		storage:   storage,
+		client:    client,
+		workers:   workers,
+		batchSize: batchSize,
+		interval:  interval,
+		backoff:   backoff.Policy{Threshold: 1, Base: interval, Max: maxBackoff},
+		inFlight:  make(map[string]struct{}),
+	}
+}
+
+// Запускает пул воркеров и блокируется до отмены контекста. После отмены новые заказы не берутся,
+// а возврат происходит, когда воркеры завершат текущие заказы. Запрос к системе начислений
+// прерывается, и заказ остаётся в очереди до следующего запуска; полученный ответ сохраняется
+func (w *Worker) Run(ctx context.Context) {
+	jobs := make(chan models.Order)
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for order := range jobs {
+				w.processOrder(ctx, order)
+				w.release(order.Number)
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.dispatch(ctx, jobs)
+
+		select {
+		case <-ctx.Done():
+			close(jobs)
+			wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Выбирает необработанные заказы и передаёт их свободным воркерам
+func (w *Worker) dispatch(ctx context.Context, jobs chan<- models.Order) {
+	orders, err := w.storage.GetPendingOrders(ctx, w.batchSize)
+	if err != nil {
+		logger.Log.Error("failed to load pending orders", zap.Error(err))
+		return
+	}
+	metrics.PendingOrders.Set(float64(len(orders)))
+
+	for _, order := range orders {
+		// Заказ уже обрабатывается одним из воркеров
+		if !w.acquire(order.Number) {
+			continue
+		}
+
+		select {
+		case jobs <- order:
+		case <-ctx.Done():
+			w.release(order.Number)
+			return
+		}
+	}
+}
+
+// Запрашивает статус заказа в системе начислений и сохраняет результат
+func (w *Worker) processOrder(ctx context.Context, order models.Order) {
+	// Запрос к системе начислений и сохранение результата попадают в одну трассу
+	ctx, span := tracing.Tracer().Start(ctx, "accrual.processOrder",
+		trace.WithAttributes(attribute.String("order.number", order.Number), attribute.String("order.status", order.Status)),
+	)
+	defer span.End()
+
+	accrualInfo, err := w.client.GetAccrualInfo(ctx, order.Number)
+	if err != nil {
+		// В любом случае заказ остаётся в очереди и будет запрошен повторно
+		switch {
+		case errors.Is(err, ErrOrderNotRegistered), errors.Is(err, ErrTooManyRequests):
+			logger.Log.Debug("accrual info not received", zap.String("order", order.Number), zap.Error(err))
+		case errors.Is(err, context.Canceled):
+			// Прерванный при остановке запрос не считается опросом
+			return
+		default:
+			tracing.RecordError(span, err)
+			logger.Log.Warn("accrual request failed", zap.String("order", order.Number), zap.Error(err))
+		}
+		w.schedule(ctx, order)
+		return
+	}
+
+	// Полученный ответ сохраняется и при остановке сервиса, иначе он будет запрошен повторно
+	storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), storeTimeout)
+	defer cancel()
+
+	switch accrualInfo.Status {
+	case "PROCESSED", "INVALID":
+		// Статус заказа и начисление фиксируются одной транзакцией
+		if _, err := w.storage.SettleOrder(storeCtx, order.Number, accrualInfo.Status, accrualInfo.Accrual); err != nil {
+			logger.Log.Error("failed to settle order", zap.String("order", order.Number), zap.Error(err))
+		}
+	case "REGISTERED", "PROCESSING":
+		if order.Status != "PROCESSING" {
+			if err := w.storage.UpdateOrderStatus(storeCtx, order.Number, "PROCESSING", money.Amount{}); err != nil {
+				logger.Log.Error("failed to update order status", zap.String("order", order.Number), zap.Error(err))
+			}
+		}
+		w.schedule(ctx, order)
+	default:
+		logger.Log.Warn("unknown accrual status", zap.String("order", order.Number), zap.String("status", accrualInfo.Status))
+		w.schedule(ctx, order)
+	}
+}
+
+// Откладывает следующий опрос заказа, не получившего окончательного статуса.
+// Срок сохраняется и при остановке сервиса, как и полученный статус
+func (w *Worker) schedule(ctx context.Context, order models.Order) {
+	storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), storeTimeout)
+	defer cancel()
+
+	delay := w.backoff.Delay(order.PollAttempts + 1)
+	if err := w.storage.ScheduleOrderPoll(storeCtx, order.Number, delay); err != nil {
+		logger.Log.Error("failed to schedule order poll", zap.String("order", order.Number), zap.Error(err))
+	}
+}
+
+func (w *Worker) acquire(orderNumber string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.inFlight[orderNumber]; ok {
+		return false
+	}
+	w.inFlight[orderNumber] = struct{}{}
+	return true
+}
+
+func (w *Worker) release(orderNumber string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.inFlight, orderNumber)
+}
@@ -0,0 +1,178 @@
+package accrual
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/money"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage/mocks"
+	"github.com/golang/mock/gomock"
+)
+
+// Тестирование обработки заказа воркером
+func TestWorker_processOrder(t *testing.T) {
+	tests := []struct {
+		name         string
+		order        models.Order
+		responseCode int
+		responseBody *models.AccrualInfo
+		expect       func(m *mocks.MockStorage)
+	}{
+		{
+			name:         "processed order",
+			order:        models.Order{UserID: 1, Number: "12345678903", Status: "NEW"},
+			responseCode: http.StatusOK,
+			responseBody: &models.AccrualInfo{OrderNumber: "12345678903", Status: "PROCESSED", Accrual: money.MustParse("500")},
+			expect: func(m *mocks.MockStorage) {
+				m.EXPECT().SettleOrder(gomock.Any(), "12345678903", "PROCESSED", money.MustParse("500")).Return(true, nil)
+			},
+		},
+		{
+			name:         "invalid order",
+			order:        models.Order{UserID: 1, Number: "12345678903", Status: "PROCESSING"},
+			responseCode: http.StatusOK,
+			responseBody: &models.AccrualInfo{OrderNumber: "12345678903", Status: "INVALID"},
+			expect: func(m *mocks.MockStorage) {
+				m.EXPECT().SettleOrder(gomock.Any(), "12345678903", "INVALID", money.Amount{}).Return(false, nil)
+			},
+		},
+		{
+			name:         "registered order",
+			order:        models.Order{UserID: 1, Number: "12345678903", Status: "NEW"},
+			responseCode: http.StatusOK,
+			responseBody: &models.AccrualInfo{OrderNumber: "12345678903", Status: "REGISTERED"},
+			expect: func(m *mocks.MockStorage) {
+				m.EXPECT().UpdateOrderStatus(gomock.Any(), "12345678903", "PROCESSING", money.Amount{}).Return(nil)
+				m.EXPECT().ScheduleOrderPoll(gomock.Any(), "12345678903", time.Second).Return(nil)
+			},
+		},
+		{
+			name:         "order still processing",
+			order:        models.Order{UserID: 1, Number: "12345678903", Status: "PROCESSING", PollAttempts: 2},
+			responseCode: http.StatusOK,
+			responseBody: &models.AccrualInfo{OrderNumber: "12345678903", Status: "PROCESSING"},
+			expect: func(m *mocks.MockStorage) {
+				// Задержка удваивается с каждым безрезультатным опросом
+				m.EXPECT().ScheduleOrderPoll(gomock.Any(), "12345678903", 4*time.Second).Return(nil)
+			},
+		},
+		{
+			name:         "order not registered",
+			order:        models.Order{UserID: 1, Number: "12345678903", Status: "NEW"},
+			responseCode: http.StatusNoContent,
+			expect: func(m *mocks.MockStorage) {
+				m.EXPECT().ScheduleOrderPoll(gomock.Any(), "12345678903", time.Second).Return(nil)
+			},
+		},
+		{
+			name:         "too many requests",
+			order:        models.Order{UserID: 1, Number: "12345678903", Status: "NEW", PollAttempts: 20},
+			responseCode: http.StatusTooManyRequests,
+			expect: func(m *mocks.MockStorage) {
+				// Задержка ограничена сверху
+				m.EXPECT().ScheduleOrderPoll(gomock.Any(), "12345678903", time.Minute).Return(nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			m := mocks.NewMockStorage(ctrl)
+			tt.expect(m)
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.responseCode)
+				if tt.responseBody != nil {
+					json.NewEncoder(w).Encode(tt.responseBody)
+				}
+			}))
+			defer ts.Close()
+
+			worker := NewWorker(m, NewClient(ts.URL), 1, 10, time.Second, time.Minute)
+			worker.processOrder(context.Background(), tt.order)
+		})
+	}
+}
+
+// Тестирование выборки заказов из БД при запуске пула
+func TestWorker_Run(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockStorage(ctrl)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m.EXPECT().GetPendingOrders(gomock.Any(), 10).Return([]models.Order{
+		{UserID: 1, Number: "12345678903", Status: "PROCESSING"},
+	}, nil).AnyTimes()
+	m.EXPECT().SettleOrder(gomock.Any(), "12345678903", "PROCESSED", money.MustParse("100")).DoAndReturn(func(ctx context.Context, orderNumber, status string, accrual money.Amount) (bool, error) {
+		cancel()
+		return true, nil
+	})
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(models.AccrualInfo{OrderNumber: "12345678903", Status: "PROCESSED", Accrual: money.MustParse("100")})
+	}))
+	defer ts.Close()
+
+	done := make(chan struct{})
+	go func() {
+		NewWorker(m, NewClient(ts.URL), 2, 10, time.Hour, time.Hour).Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("worker did not stop after context cancellation")
+	}
+}
+
+// Клиент, во время запроса к которому начинается остановка сервиса
+type cancellingClient struct {
+	cancel context.CancelFunc
+	info   models.AccrualInfo
+}
+
+func (c cancellingClient) GetAccrualInfo(ctx context.Context, orderNumber string) (*models.AccrualInfo, error) {
+	c.cancel()
+	return &c.info, nil
+}
+
+// Тестирование сохранения полученного начисления при остановке сервиса
+func TestWorker_processOrder_Shutdown(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := mocks.NewMockStorage(ctrl)
+	m.EXPECT().SettleOrder(gomock.Any(), "12345678903", "PROCESSED", money.MustParse("100")).DoAndReturn(
+		func(ctx context.Context, orderNumber, status string, accrual money.Amount) (bool, error) {
+			// Контекст записи не отменяется вместе с контекстом воркера
+			if ctx.Err() != nil {
+				t.Errorf("settle context is cancelled: %v", ctx.Err())
+			}
+			return true, nil
+		},
+	)
+
+	client := cancellingClient{
+		cancel: cancel,
+		info:   models.AccrualInfo{OrderNumber: "12345678903", Status: "PROCESSED", Accrual: money.MustParse("100")},
+	}
+	worker := NewWorker(m, client, 1, 10, time.Second, time.Minute)
+	worker.processOrder(ctx, models.Order{UserID: 1, Number: "12345678903", Status: "NEW"})
+}
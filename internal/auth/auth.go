@@ -1,52 +1,141 @@
 package auth
 
 import (
+	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
-type AuthJWT interface {
-	BuildJWTString(userID string) (string, error)
-	GetUserID(tokenString string) (string, error)
-}
-
 type Claims struct {
     jwt.RegisteredClaims
-    UserID string
+    UserID    string
+    SessionID string `json:"sid,omitempty"`
+    Role      string `json:"role,omitempty"`
+    // Назначение токена. У access-токена не задано, остальные токены не дают доступа к API
+    Purpose string `json:"purpose,omitempty"`
 }
 
-const TokenExp = time.Hour * 24
-const SecretKey = "supersecretkey"
+// Срок действия access-токена. Для продления используется refresh-токен
+const TokenExp = time.Minute * 15
 
 type userContextKey string
 const UserIDKey userContextKey = "user_id"
+const SessionIDKey userContextKey = "session_id"
+const RoleKey userContextKey = "role"
+
+// Роли пользователей
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Ключи подписи токенов. До вызова Initialize используется случайный секрет
+var keys = newRandomKeySet()
+
+// Ошибки проверки токена
+var (
+	ErrUnknownKeyID         = errors.New("unknown jwt key id")
+	ErrUnexpectedSignMethod = errors.New("unexpected jwt signing method")
+	ErrUnexpectedPurpose    = errors.New("unexpected jwt purpose")
+)
+
+// Настройка ключей подписи: файл ключей имеет приоритет над секретом
+func Initialize(secret, keysFile string) error {
+	var (
+		ks  *KeySet
+		err error
+	)
+	switch {
+	case keysFile != "":
+		ks, err = LoadKeySet(keysFile)
+	case secret != "":
+		ks, err = NewSecretKeySet([]byte(secret))
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
 
-func BuildJWTString(userID string) (string, error) {
-    token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims {
-        RegisteredClaims: jwt.RegisteredClaims{
-            ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenExp)),
-        },
-        UserID: userID,
-    })
+	SetKeySet(ks)
+	return nil
+}
+
+// Замена набора ключей
+func SetKeySet(ks *KeySet) {
+	keys = ks
+}
 
-    tokenString, err := token.SignedString([]byte(SecretKey))
-    if err != nil {
-        return "", err
-    }
+// Выпускает токен и возвращает время окончания его действия
+func IssueToken(userID, sessionID, role string) (string, time.Time, error) {
+	key := keys.Active()
+	expiresAt := time.Now().Add(TokenExp)
+	token := jwt.NewWithClaims(key.Method, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+		UserID:    userID,
+		SessionID: sessionID,
+		Role:      role,
+	})
+	token.Header["kid"] = key.ID
 
-    return tokenString, nil
+	tokenString, err := token.SignedString(key.SignKey)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	// Время в токене хранится с точностью до секунды
+	return tokenString, expiresAt.Truncate(time.Second), nil
 }
 
 func GetUserID(tokenString string) (string, error) {
-    claims := &Claims{}
-
-    _, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
-        return []byte(SecretKey), nil
-    })
+	claims, err := ParseToken(tokenString)
 	if err != nil {
 		return "", err
 	}
 
-    return claims.UserID, nil
-}
\ No newline at end of file
+	return claims.UserID, nil
+}
+
+// Проверяет подпись и срок действия access-токена и возвращает его содержимое
+func ParseToken(tokenString string) (*Claims, error) {
+	return parseToken(tokenString, "")
+}
+
+func parseToken(tokenString, purpose string) (*Claims, error) {
+	claims := &Claims{}
+
+	_, err := jwt.ParseWithClaims(tokenString, claims, verifyKey, jwt.WithValidMethods(keys.Algorithms()))
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != purpose {
+		return nil, ErrUnexpectedPurpose
+	}
+	// Токены, выпущенные до появления ролей, принадлежат обычным пользователям
+	if claims.Role == "" {
+		claims.Role = RoleUser
+	}
+
+	return claims, nil
+}
+
+// Выбор ключа проверки по заголовку kid. Алгоритм токена должен совпадать с алгоритмом ключа,
+// иначе, например, публичный RSA-ключ мог бы быть использован как HMAC-секрет
+func verifyKey(t *jwt.Token) (interface{}, error) {
+	key := keys.Active()
+	if kid, ok := t.Header["kid"]; ok {
+		id, _ := kid.(string)
+		if key, ok = keys.Lookup(id); !ok {
+			return nil, ErrUnknownKeyID
+		}
+	}
+
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, ErrUnexpectedSignMethod
+	}
+
+	return key.VerifyKey, nil
+}
@@ -8,10 +8,10 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func TestBuildJWTString(t *testing.T) {
+func TestIssueToken(t *testing.T) {
 	// Тестируем корректный userID
 	userID := "testUser123"
-	tokenString, err := BuildJWTString(userID)
+	tokenString, expiresAt, err := IssueToken(userID, "session1", RoleUser)
 
 	// Проверяем, что ошибки при создании токена нет
 	assert.NoError(t, err)
@@ -20,20 +20,23 @@ func TestBuildJWTString(t *testing.T) {
 	// Разбираем токен, чтобы проверить его содержимое
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
-		return []byte(SecretKey), nil
+		return keys.Active().VerifyKey, nil
 	})
 	assert.NoError(t, err)
 	assert.NotNil(t, token)
 
 	// Проверяем, что UserID и время истечения срока действия правильные
 	assert.Equal(t, userID, claims.UserID)
+	assert.Equal(t, "session1", claims.SessionID)
+	assert.Equal(t, RoleUser, claims.Role)
 	assert.WithinDuration(t, time.Now().Add(TokenExp), claims.ExpiresAt.Time, time.Second)
+	assert.True(t, expiresAt.Equal(claims.ExpiresAt.Time))
 }
 
 func TestGetUserID(t *testing.T) {
 	// Генерируем правильный JWT токен
 	userID := "testUser123"
-	tokenString, err := BuildJWTString(userID)
+	tokenString, _, err := IssueToken(userID, "session1", RoleUser)
 	assert.NoError(t, err)
 
 	// Получаем userID из токена
@@ -56,16 +59,16 @@ func TestGetUserID_InvalidToken(t *testing.T) {
 	assert.Empty(t, retrievedUserID)
 }
 
-func TestBuildJWTString_EmptyUserID(t *testing.T) {
+func TestIssueToken_EmptyUserID(t *testing.T) {
 	// Тестируем создание токена с пустым userID
-	tokenString, err := BuildJWTString("")
+	tokenString, _, err := IssueToken("", "", RoleUser)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, tokenString)
 
 	// Разбираем токен и проверяем его содержимое
 	claims := &Claims{}
 	_, err = jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
-		return []byte(SecretKey), nil
+		return keys.Active().VerifyKey, nil
 	})
 	assert.NoError(t, err)
 
@@ -73,7 +76,7 @@ func TestBuildJWTString_EmptyUserID(t *testing.T) {
 	assert.Equal(t, "", claims.UserID)
 }
 
-func TestBuildJWTString_ExpiredToken(t *testing.T) {
+func TestIssueToken_ExpiredToken(t *testing.T) {
 	// Создаем токен с истекшим сроком действия
 	claims := Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
@@ -82,10 +85,25 @@ func TestBuildJWTString_ExpiredToken(t *testing.T) {
 		UserID: "expiredUser",
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(SecretKey))
+	tokenString, err := token.SignedString(keys.Active().SignKey)
 	assert.NoError(t, err)
 
 	// Пытаемся получить userID из истекшего токена
 	_, err = GetUserID(tokenString)
 	assert.Error(t, err) // Токен должен быть истекшим
-}
\ No newline at end of file
+}
+
+// Тестирование выпуска refresh-токена
+func TestNewRefreshToken(t *testing.T) {
+	token, hash, err := NewRefreshToken()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+
+	// В хранилище попадает только хеш, по которому токен затем находится
+	assert.NotEqual(t, token, hash)
+	assert.Equal(t, hash, HashRefreshToken(token))
+
+	other, _, err := NewRefreshToken()
+	assert.NoError(t, err)
+	assert.NotEqual(t, token, other)
+}
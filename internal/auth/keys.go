@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Ключ подписи токенов
+type Key struct {
+	ID        string
+	Method    jwt.SigningMethod
+	SignKey   interface{} // nil, если ключ оставлен только для проверки выпущенных токенов
+	VerifyKey interface{}
+}
+
+// Набор ключей: активный ключ подписывает новые токены, остальные только проверяют.
+// Это позволяет менять ключ без выхода пользователей из системы
+type KeySet struct {
+	active string
+	keys   map[string]Key
+}
+
+func NewKeySet(active string, keys ...Key) (*KeySet, error) {
+	ks := &KeySet{active: active, keys: make(map[string]Key, len(keys))}
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, errors.New("jwt key id is empty")
+		}
+		if _, ok := ks.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate jwt key id %q", key.ID)
+		}
+		ks.keys[key.ID] = key
+	}
+
+	activeKey, ok := ks.keys[active]
+	if !ok {
+		return nil, fmt.Errorf("active jwt key %q not found", active)
+	}
+	if activeKey.SignKey == nil {
+		return nil, fmt.Errorf("active jwt key %q has no private key", active)
+	}
+
+	return ks, nil
+}
+
+// Ключ для подписи новых токенов
+func (ks *KeySet) Active() Key {
+	return ks.keys[ks.active]
+}
+
+// Ключ для проверки токена по заголовку kid
+func (ks *KeySet) Lookup(kid string) (Key, bool) {
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+// Алгоритмы всех ключей набора
+func (ks *KeySet) Algorithms() []string {
+	seen := make(map[string]bool)
+	var algs []string
+	for _, key := range ks.keys {
+		alg := key.Method.Alg()
+		if !seen[alg] {
+			seen[alg] = true
+			algs = append(algs, alg)
+		}
+	}
+	return algs
+}
+
+// Идентификатор ключа, созданного из секрета, переданного флагом или переменной окружения
+const secretKeyID = "default"
+
+// Набор из одного HMAC-ключа
+func NewSecretKeySet(secret []byte) (*KeySet, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("jwt secret is empty")
+	}
+	return NewKeySet(secretKeyID, Key{
+		ID:        secretKeyID,
+		Method:    jwt.SigningMethodHS256,
+		SignKey:   secret,
+		VerifyKey: secret,
+	})
+}
+
+// Описание ключа в файле ключей
+type keyConfig struct {
+	ID             string `json:"kid"`
+	Algorithm      string `json:"alg"`
+	Secret         string `json:"secret,omitempty"`
+	PrivateKeyFile string `json:"private_key_file,omitempty"`
+	PublicKeyFile  string `json:"public_key_file,omitempty"`
+}
+
+// Формат файла ключей
+type keysFileConfig struct {
+	Active string      `json:"active"`
+	Keys   []keyConfig `json:"keys"`
+}
+
+// Загружает набор ключей из JSON-файла
+func LoadKeySet(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg keysFileConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse jwt keys file: %w", err)
+	}
+
+	keys := make([]Key, 0, len(cfg.Keys))
+	for _, kc := range cfg.Keys {
+		key, err := kc.load()
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %q: %w", kc.ID, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return NewKeySet(cfg.Active, keys...)
+}
+
+func (kc keyConfig) load() (Key, error) {
+	key := Key{ID: kc.ID, Method: jwt.GetSigningMethod(kc.Algorithm)}
+
+	switch key.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if kc.Secret == "" {
+			return Key{}, errors.New("secret is empty")
+		}
+		key.SignKey = []byte(kc.Secret)
+		key.VerifyKey = []byte(kc.Secret)
+	case *jwt.SigningMethodRSA:
+		if kc.PrivateKeyFile != "" {
+			privateKey, err := readPEM(kc.PrivateKeyFile, jwt.ParseRSAPrivateKeyFromPEM)
+			if err != nil {
+				return Key{}, err
+			}
+			key.SignKey = privateKey
+			key.VerifyKey = &privateKey.PublicKey
+		} else {
+			publicKey, err := readPEM(kc.PublicKeyFile, jwt.ParseRSAPublicKeyFromPEM)
+			if err != nil {
+				return Key{}, err
+			}
+			key.VerifyKey = publicKey
+		}
+	case *jwt.SigningMethodEd25519:
+		if kc.PrivateKeyFile != "" {
+			privateKey, err := readPEM(kc.PrivateKeyFile, jwt.ParseEdPrivateKeyFromPEM)
+			if err != nil {
+				return Key{}, err
+			}
+			edKey, ok := privateKey.(ed25519.PrivateKey)
+			if !ok {
+				return Key{}, errors.New("not an Ed25519 private key")
+			}
+			key.SignKey = edKey
+			key.VerifyKey = edKey.Public()
+		} else {
+			publicKey, err := readPEM(kc.PublicKeyFile, jwt.ParseEdPublicKeyFromPEM)
+			if err != nil {
+				return Key{}, err
+			}
+			key.VerifyKey = publicKey
+		}
+	default:
+		return Key{}, fmt.Errorf("unsupported algorithm %q", kc.Algorithm)
+	}
+
+	return key, nil
+}
+
+func readPEM[T any](path string, parse func([]byte) (T, error)) (T, error) {
+	var zero T
+	if path == "" {
+		return zero, errors.New("key file is not set")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return zero, err
+	}
+	return parse(data)
+}
+
+// Набор со случайным секретом. Токены перестают действовать после перезапуска
+func newRandomKeySet() *KeySet {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	ks, err := NewSecretKeySet(secret)
+	if err != nil {
+		panic(err)
+	}
+	return ks
+}
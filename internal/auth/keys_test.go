@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Подмена набора ключей на время теста
+func useKeySet(t *testing.T, ks *KeySet) {
+	previous := keys
+	SetKeySet(ks)
+	t.Cleanup(func() { SetKeySet(previous) })
+}
+
+func hmacKey(id, secret string) Key {
+	return Key{ID: id, Method: jwt.SigningMethodHS256, SignKey: []byte(secret), VerifyKey: []byte(secret)}
+}
+
+// Тестирование ротации: токены, подписанные прежним ключом, продолжают проверяться
+func TestKeyRotation(t *testing.T) {
+	oldSet, err := NewKeySet("old", hmacKey("old", "old-secret"))
+	require.NoError(t, err)
+	useKeySet(t, oldSet)
+
+	oldToken, _, err := IssueToken("1", "session1", RoleUser)
+	require.NoError(t, err)
+
+	newSet, err := NewKeySet("new", hmacKey("new", "new-secret"), hmacKey("old", "old-secret"))
+	require.NoError(t, err)
+	SetKeySet(newSet)
+
+	userID, err := GetUserID(oldToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", userID)
+
+	// Новые токены подписываются активным ключом
+	newToken, _, err := IssueToken("2", "session1", RoleUser)
+	require.NoError(t, err)
+	token, _, err := new(jwt.Parser).ParseUnverified(newToken, &Claims{})
+	require.NoError(t, err)
+	assert.Equal(t, "new", token.Header["kid"])
+
+	// После удаления старого ключа его токены отклоняются
+	onlyNew, err := NewKeySet("new", hmacKey("new", "new-secret"))
+	require.NoError(t, err)
+	SetKeySet(onlyNew)
+	_, err = GetUserID(oldToken)
+	assert.ErrorIs(t, err, ErrUnknownKeyID)
+}
+
+// Тестирование строгой проверки алгоритма подписи
+func TestGetUserID_SigningMethod(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	require.NoError(t, err)
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	ks, err := NewKeySet("rsa", Key{ID: "rsa", Method: jwt.SigningMethodRS256, SignKey: privateKey, VerifyKey: &privateKey.PublicKey})
+	require.NoError(t, err)
+	useKeySet(t, ks)
+
+	claims := Claims{UserID: "1"}
+
+	tests := []struct {
+		name  string
+		token func() (string, error)
+	}{
+		{
+			// Публичный ключ, использованный как HMAC-секрет
+			name: "hmac with public key",
+			token: func() (string, error) {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+				token.Header["kid"] = "rsa"
+				return token.SignedString(publicPEM)
+			},
+		},
+		{
+			name: "none algorithm",
+			token: func() (string, error) {
+				token := jwt.NewWithClaims(jwt.SigningMethodNone, claims)
+				token.Header["kid"] = "rsa"
+				return token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+			},
+		},
+		{
+			name: "unknown kid",
+			token: func() (string, error) {
+				token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+				token.Header["kid"] = "other"
+				return token.SignedString(privateKey)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenString, err := tt.token()
+			require.NoError(t, err)
+
+			userID, err := GetUserID(tokenString)
+			assert.Error(t, err)
+			assert.Empty(t, userID)
+		})
+	}
+}
+
+// Тестирование загрузки ключей RSA и Ed25519 из файла
+func TestLoadKeySet(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, data, 0o600))
+		return path
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaPath := writeFile("rsa.pem", pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(rsaKey),
+	}))
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	edDER, err := x509.MarshalPKCS8PrivateKey(edKey)
+	require.NoError(t, err)
+	edPath := writeFile("ed25519.pem", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: edDER}))
+
+	config, err := json.Marshal(keysFileConfig{
+		Active: "ed",
+		Keys: []keyConfig{
+			{ID: "ed", Algorithm: "EdDSA", PrivateKeyFile: edPath},
+			{ID: "rsa", Algorithm: "RS256", PrivateKeyFile: rsaPath},
+		},
+	})
+	require.NoError(t, err)
+
+	ks, err := LoadKeySet(writeFile("keys.json", config))
+	require.NoError(t, err)
+	useKeySet(t, ks)
+
+	tokenString, _, err := IssueToken("42", "session1", RoleUser)
+	require.NoError(t, err)
+	userID, err := GetUserID(tokenString)
+	assert.NoError(t, err)
+	assert.Equal(t, "42", userID)
+
+	// Токен, подписанный неактивным ключом набора
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, Claims{UserID: "7"})
+	token.Header["kid"] = "rsa"
+	tokenString, err = token.SignedString(rsaKey)
+	require.NoError(t, err)
+	userID, err = GetUserID(tokenString)
+	assert.NoError(t, err)
+	assert.Equal(t, "7", userID)
+
+	// Ошибки конфигурации
+	_, err = LoadKeySet(writeFile("missing-active.json", []byte(`{"active":"x","keys":[{"kid":"a","alg":"HS256","secret":"s"}]}`)))
+	assert.Error(t, err)
+	_, err = LoadKeySet(writeFile("bad-alg.json", []byte(`{"active":"a","keys":[{"kid":"a","alg":"none"}]}`)))
+	assert.Error(t, err)
+}
@@ -0,0 +1,16 @@
+package auth
+
+import "time"
+
+// Срок действия токена сброса пароля
+const PasswordResetTokenExp = time.Hour
+
+// Новый токен сброса пароля и его хеш. В хранилище сохраняется только хеш
+func NewPasswordResetToken() (token, hash string, err error) {
+	return newOpaqueToken()
+}
+
+// Хеш токена сброса пароля для поиска в хранилище
+func HashPasswordResetToken(token string) string {
+	return hashOpaqueToken(token)
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+// Срок действия refresh-токена. Каждый обмен токена продлевает сессию на этот срок
+const RefreshTokenExp = time.Hour * 24 * 30
+
+// Новый идентификатор сессии
+func NewSessionID() (string, error) {
+	return newID()
+}
+
+// Случайный идентификатор из 16 байт в hex
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Новый refresh-токен и его хеш. В хранилище сохраняется только хеш
+func NewRefreshToken() (token, hash string, err error) {
+	return newOpaqueToken()
+}
+
+// Хеш refresh-токена для поиска в хранилище
+func HashRefreshToken(token string) string {
+	return hashOpaqueToken(token)
+}
+
+// Случайный токен, не содержащий данных, и его хеш
+func newOpaqueToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashOpaqueToken(token), nil
+}
+
+func hashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
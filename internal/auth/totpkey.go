@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Префикс секрета TOTP, зашифрованного AES-256-GCM. Секреты без префикса
+// сохранены до появления шифрования и хранятся открытым текстом
+const totpSecretPrefix = "v1:"
+
+var ErrTOTPKeyNotConfigured = errors.New("totp encryption key is not configured")
+
+// Ключ шифрования секретов TOTP. nil, если ключ не задан
+var totpAEAD cipher.AEAD
+
+// Задаёт ключ шифрования секретов TOTP: 32 байта в base64. Пустая строка оставляет шифрование выключенным
+func InitializeTOTPKey(key string) error {
+	if key == "" {
+		totpAEAD = nil
+		return nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return fmt.Errorf("decode totp encryption key: %w", err)
+	}
+	if len(raw) != 32 {
+		return fmt.Errorf("totp encryption key must be 32 bytes, got %d", len(raw))
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	totpAEAD = aead
+	return nil
+}
+
+// Ключ шифрования секретов TOTP задан
+func TOTPKeyConfigured() bool {
+	return totpAEAD != nil
+}
+
+// Шифрует секрет TOTP для хранения в БД. Идентификатор пользователя входит
+// в проверяемые данные, поэтому секрет нельзя перенести в строку другого пользователя
+func SealTOTPSecret(userID int, secret string) (string, error) {
+	if totpAEAD == nil {
+		return "", ErrTOTPKeyNotConfigured
+	}
+
+	nonce := make([]byte, totpAEAD.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := totpAEAD.Seal(nonce, nonce, []byte(secret), []byte(strconv.Itoa(userID)))
+
+	return totpSecretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Расшифровывает секрет TOTP из БД. Секрет, сохранённый до появления шифрования, возвращается как есть
+func OpenTOTPSecret(userID int, stored string) (string, error) {
+	encoded, ok := strings.CutPrefix(stored, totpSecretPrefix)
+	if !ok {
+		return stored, nil
+	}
+	if totpAEAD == nil {
+		return "", ErrTOTPKeyNotConfigured
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("decode totp secret: %w", err)
+	}
+	if len(sealed) < totpAEAD.NonceSize() {
+		return "", errors.New("decode totp secret: ciphertext too short")
+	}
+
+	nonce, ciphertext := sealed[:totpAEAD.NonceSize()], sealed[totpAEAD.NonceSize():]
+	secret, err := totpAEAD.Open(nil, nonce, ciphertext, []byte(strconv.Itoa(userID)))
+	if err != nil {
+		return "", fmt.Errorf("decrypt totp secret: %w", err)
+	}
+	return string(secret), nil
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Подмена ключа шифрования секретов TOTP на время теста
+func useTOTPKey(t *testing.T, key string) {
+	previous := totpAEAD
+	require.NoError(t, InitializeTOTPKey(key))
+	t.Cleanup(func() { totpAEAD = previous })
+}
+
+func randomTOTPKey(t *testing.T) string {
+	raw := make([]byte, 32)
+	_, err := rand.Read(raw)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+// Тестирование шифрования секрета TOTP
+func TestSealTOTPSecret(t *testing.T) {
+	useTOTPKey(t, randomTOTPKey(t))
+
+	sealed, err := SealTOTPSecret(1, "JBSWY3DPEHPK3PXP")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(sealed, totpSecretPrefix))
+	assert.NotContains(t, sealed, "JBSWY3DPEHPK3PXP")
+
+	secret, err := OpenTOTPSecret(1, sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", secret)
+
+	// Секрет привязан к пользователю
+	_, err = OpenTOTPSecret(2, sealed)
+	assert.Error(t, err)
+
+	// Другим ключом секрет не расшифровывается
+	useTOTPKey(t, randomTOTPKey(t))
+	_, err = OpenTOTPSecret(1, sealed)
+	assert.Error(t, err)
+
+	// Секрет, сохранённый до появления шифрования, читается как есть
+	secret, err = OpenTOTPSecret(1, "JBSWY3DPEHPK3PXP")
+	require.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", secret)
+}
+
+// Тестирование работы без ключа шифрования
+func TestSealTOTPSecret_NoKey(t *testing.T) {
+	useTOTPKey(t, "")
+
+	assert.False(t, TOTPKeyConfigured())
+	_, err := SealTOTPSecret(1, "JBSWY3DPEHPK3PXP")
+	assert.ErrorIs(t, err, ErrTOTPKeyNotConfigured)
+	_, err = OpenTOTPSecret(1, totpSecretPrefix+"AAAA")
+	assert.ErrorIs(t, err, ErrTOTPKeyNotConfigured)
+}
+
+// Тестирование проверки формата ключа
+func TestInitializeTOTPKey(t *testing.T) {
+	useTOTPKey(t, "")
+
+	assert.Error(t, InitializeTOTPKey("not base64"))
+	assert.Error(t, InitializeTOTPKey(base64.StdEncoding.EncodeToString([]byte("short"))))
+	assert.False(t, TOTPKeyConfigured())
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Назначение токена второго шага входа
+const PurposeTwoFactor = "2fa"
+
+// Срок, за который нужно ввести код второго фактора
+const ChallengeTokenExp = time.Minute * 5
+
+// Токен, подтверждающий первый шаг входа. Обменивается на access-токен после проверки кода.
+// Возвращает также идентификатор токена (jti), по которому хранилище погашает его после входа
+func BuildChallengeToken(userID string) (tokenString, challengeID string, err error) {
+	challengeID, err = newID()
+	if err != nil {
+		return "", "", err
+	}
+
+	key := keys.Active()
+	token := jwt.NewWithClaims(key.Method, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        challengeID,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ChallengeTokenExp)),
+		},
+		UserID:  userID,
+		Purpose: PurposeTwoFactor,
+	})
+	token.Header["kid"] = key.ID
+
+	tokenString, err = token.SignedString(key.SignKey)
+	if err != nil {
+		return "", "", err
+	}
+	return tokenString, challengeID, nil
+}
+
+// Возвращает пользователя и идентификатор токена второго шага входа
+func ParseChallengeToken(tokenString string) (userID, challengeID string, err error) {
+	claims, err := parseToken(tokenString, PurposeTwoFactor)
+	if err != nil {
+		return "", "", err
+	}
+	if claims.ID == "" {
+		return "", "", jwt.ErrTokenInvalidId
+	}
+	return claims.UserID, claims.ID, nil
+}
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Новые коды восстановления вида xxxxx-xxxxx и их хеши
+func NewRecoveryCodes(n int) (codes, hashes []string, err error) {
+	for i := 0; i < n; i++ {
+		b := make([]byte, 7)
+		if _, err = rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))[:10]
+		code = code[:5] + "-" + code[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// Хеш кода восстановления. Регистр, пробелы и дефисы не учитываются
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return hashOpaqueToken(code)
+}
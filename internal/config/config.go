@@ -3,18 +3,78 @@ package config
 import (
 	"flag"
 	"os"
+	"strconv"
+	"time"
 )
 
 var FlagRunAddr string
 var FlagLogLevel string
 var FlagDatabaseURI string
 var FlagAccrualSystemAddress string
+var FlagAccrualWorkers int
+var FlagAccrualPollInterval time.Duration
+var FlagAccrualBatchSize int
+var FlagAccrualMaxBackoff time.Duration
+var FlagJWTSecret string
+var FlagJWTKeysFile string
+var FlagCookieDomain string
+var FlagCookieSecure bool
+var FlagCookieSameSite string
+var FlagCSRFProtection bool
+var FlagLoginMaxAttempts int
+var FlagLoginLockout time.Duration
+var FlagLoginMaxLockout time.Duration
+var FlagLoginAttemptWindow time.Duration
+var FlagTrustProxyHeaders bool
+var FlagLoginPattern string
+var FlagPasswordMinLength int
+var FlagPasswordCharClasses int
+var FlagBreachedPasswordsFile string
+var FlagAccountBalancePolicy string
+var FlagShutdownTimeout time.Duration
+var FlagHealthCheckTimeout time.Duration
+var FlagReadinessRequireAccrual bool
+var FlagTracingExporter string
+var FlagTracingSampleRatio float64
+var FlagTracingTrustIncoming bool
+var FlagNotifyWebhookURL string
+var FlagNotifyWebhookToken string
+var FlagTOTPEncryptionKey string
 
 func ParseFlags() {
 	flag.StringVar(&FlagRunAddr, "a", "localhost:8080", "адрес запуска HTTP-сервера")
 	flag.StringVar(&FlagLogLevel, "l", "info", "log level")
 	flag.StringVar(&FlagDatabaseURI, "d", "", "адрес подключения к БД")
 	flag.StringVar(&FlagAccrualSystemAddress, "r", "http://127.0.0.1:8081", "адрес системы расчёта начислений")
+	flag.IntVar(&FlagAccrualWorkers, "accrual-workers", 5, "количество воркеров опроса системы начислений")
+	flag.DurationVar(&FlagAccrualPollInterval, "accrual-poll-interval", time.Second, "интервал выборки необработанных заказов")
+	flag.IntVar(&FlagAccrualBatchSize, "accrual-batch-size", 100, "максимальное число заказов, выбираемых за один цикл опроса")
+	flag.DurationVar(&FlagAccrualMaxBackoff, "accrual-max-backoff", 5*time.Minute, "максимальная задержка повторного опроса заказа")
+	flag.StringVar(&FlagJWTSecret, "jwt-secret", "", "секрет подписи JWT (HS256)")
+	flag.StringVar(&FlagJWTKeysFile, "jwt-keys-file", "", "JSON-файл с ключами подписи JWT")
+	flag.StringVar(&FlagCookieDomain, "cookie-domain", "", "домен куки авторизации")
+	flag.BoolVar(&FlagCookieSecure, "cookie-secure", true, "передавать куки авторизации только по HTTPS")
+	flag.StringVar(&FlagCookieSameSite, "cookie-same-site", "lax", "режим SameSite куки авторизации: lax, strict или none")
+	flag.BoolVar(&FlagCSRFProtection, "csrf", true, "проверка CSRF-токена при авторизации по куки")
+	flag.IntVar(&FlagLoginMaxAttempts, "login-max-attempts", 5, "число неудачных попыток входа до блокировки")
+	flag.DurationVar(&FlagLoginLockout, "login-lockout", time.Minute, "время первой блокировки входа, далее удваивается")
+	flag.DurationVar(&FlagLoginMaxLockout, "login-max-lockout", time.Hour, "максимальное время блокировки входа")
+	flag.DurationVar(&FlagLoginAttemptWindow, "login-attempt-window", 24*time.Hour, "время после последней неудачи, через которое счётчик попыток входа обнуляется")
+	flag.BoolVar(&FlagTrustProxyHeaders, "trust-proxy-headers", false, "определять адрес клиента по X-Forwarded-For и X-Real-IP")
+	flag.StringVar(&FlagLoginPattern, "login-pattern", `^[A-Za-z0-9._@+-]+$`, "регулярное выражение допустимого логина")
+	flag.IntVar(&FlagPasswordMinLength, "password-min-length", 8, "минимальная длина пароля")
+	flag.IntVar(&FlagPasswordCharClasses, "password-char-classes", 1, "минимальное число классов символов в пароле")
+	flag.StringVar(&FlagBreachedPasswordsFile, "breached-passwords-file", "", "файл со списком утекших паролей")
+	flag.DurationVar(&FlagShutdownTimeout, "shutdown-timeout", 10*time.Second, "время на завершение запросов и воркеров при остановке")
+	flag.DurationVar(&FlagHealthCheckTimeout, "health-check-timeout", 2*time.Second, "тайм-аут проверки одной зависимости в /readyz")
+	flag.BoolVar(&FlagReadinessRequireAccrual, "readiness-require-accrual", false, "считать сервис неготовым при недоступности системы начислений")
+	flag.StringVar(&FlagTracingExporter, "tracing-exporter", "none", "экспорт трасс OpenTelemetry: none, stdout или otlp")
+	flag.Float64Var(&FlagTracingSampleRatio, "tracing-sample-ratio", 0.1, "доля записываемых трасс от 0 до 1")
+	flag.BoolVar(&FlagTracingTrustIncoming, "tracing-trust-incoming", false, "продолжать трассу из заголовка traceparent входящего запроса")
+	flag.StringVar(&FlagNotifyWebhookURL, "notify-webhook-url", "", "адрес сервиса уведомлений для доставки токенов сброса пароля")
+	flag.StringVar(&FlagNotifyWebhookToken, "notify-webhook-token", "", "токен авторизации в сервисе уведомлений")
+	flag.StringVar(&FlagTOTPEncryptionKey, "totp-encryption-key", "", "ключ шифрования секретов TOTP: 32 байта в base64")
+	flag.StringVar(&FlagAccountBalancePolicy, "account-balance-policy", "archive", "остаток баланса при удалении учётной записи: forfeit или archive")
 
 	flag.Parse()
 
@@ -27,4 +87,91 @@ func ParseFlags() {
 	if envAccrualSystemAddress := os.Getenv("ACCRUAL_SYSTEM_ADDRESS"); envAccrualSystemAddress != "" {
         FlagAccrualSystemAddress = envAccrualSystemAddress
     }
-}
\ No newline at end of file
+	if envAccrualWorkers, err := strconv.Atoi(os.Getenv("ACCRUAL_WORKERS")); err == nil && envAccrualWorkers > 0 {
+		FlagAccrualWorkers = envAccrualWorkers
+	}
+	if envAccrualPollInterval, err := time.ParseDuration(os.Getenv("ACCRUAL_POLL_INTERVAL")); err == nil && envAccrualPollInterval > 0 {
+		FlagAccrualPollInterval = envAccrualPollInterval
+	}
+	if envAccrualBatchSize, err := strconv.Atoi(os.Getenv("ACCRUAL_BATCH_SIZE")); err == nil && envAccrualBatchSize > 0 {
+		FlagAccrualBatchSize = envAccrualBatchSize
+	}
+	if envAccrualMaxBackoff, err := time.ParseDuration(os.Getenv("ACCRUAL_MAX_BACKOFF")); err == nil && envAccrualMaxBackoff > 0 {
+		FlagAccrualMaxBackoff = envAccrualMaxBackoff
+	}
+	if envJWTSecret := os.Getenv("JWT_SECRET"); envJWTSecret != "" {
+		FlagJWTSecret = envJWTSecret
+	}
+	if envJWTKeysFile := os.Getenv("JWT_KEYS_FILE"); envJWTKeysFile != "" {
+		FlagJWTKeysFile = envJWTKeysFile
+	}
+	if envCookieDomain := os.Getenv("COOKIE_DOMAIN"); envCookieDomain != "" {
+		FlagCookieDomain = envCookieDomain
+	}
+	if envCookieSecure, err := strconv.ParseBool(os.Getenv("COOKIE_SECURE")); err == nil {
+		FlagCookieSecure = envCookieSecure
+	}
+	if envCookieSameSite := os.Getenv("COOKIE_SAME_SITE"); envCookieSameSite != "" {
+		FlagCookieSameSite = envCookieSameSite
+	}
+	if envCSRFProtection, err := strconv.ParseBool(os.Getenv("CSRF_PROTECTION")); err == nil {
+		FlagCSRFProtection = envCSRFProtection
+	}
+	if envLoginMaxAttempts, err := strconv.Atoi(os.Getenv("LOGIN_MAX_ATTEMPTS")); err == nil && envLoginMaxAttempts > 0 {
+		FlagLoginMaxAttempts = envLoginMaxAttempts
+	}
+	if envLoginLockout, err := time.ParseDuration(os.Getenv("LOGIN_LOCKOUT")); err == nil && envLoginLockout > 0 {
+		FlagLoginLockout = envLoginLockout
+	}
+	if envLoginMaxLockout, err := time.ParseDuration(os.Getenv("LOGIN_MAX_LOCKOUT")); err == nil && envLoginMaxLockout > 0 {
+		FlagLoginMaxLockout = envLoginMaxLockout
+	}
+	if envLoginAttemptWindow, err := time.ParseDuration(os.Getenv("LOGIN_ATTEMPT_WINDOW")); err == nil && envLoginAttemptWindow > 0 {
+		FlagLoginAttemptWindow = envLoginAttemptWindow
+	}
+	if envTrustProxyHeaders, err := strconv.ParseBool(os.Getenv("TRUST_PROXY_HEADERS")); err == nil {
+		FlagTrustProxyHeaders = envTrustProxyHeaders
+	}
+	if envLoginPattern := os.Getenv("LOGIN_PATTERN"); envLoginPattern != "" {
+		FlagLoginPattern = envLoginPattern
+	}
+	if envPasswordMinLength, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil && envPasswordMinLength > 0 {
+		FlagPasswordMinLength = envPasswordMinLength
+	}
+	if envPasswordCharClasses, err := strconv.Atoi(os.Getenv("PASSWORD_CHAR_CLASSES")); err == nil && envPasswordCharClasses > 0 {
+		FlagPasswordCharClasses = envPasswordCharClasses
+	}
+	if envBreachedPasswordsFile := os.Getenv("BREACHED_PASSWORDS_FILE"); envBreachedPasswordsFile != "" {
+		FlagBreachedPasswordsFile = envBreachedPasswordsFile
+	}
+	if envShutdownTimeout, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT")); err == nil && envShutdownTimeout > 0 {
+		FlagShutdownTimeout = envShutdownTimeout
+	}
+	if envHealthCheckTimeout, err := time.ParseDuration(os.Getenv("HEALTH_CHECK_TIMEOUT")); err == nil && envHealthCheckTimeout > 0 {
+		FlagHealthCheckTimeout = envHealthCheckTimeout
+	}
+	if envReadinessRequireAccrual, err := strconv.ParseBool(os.Getenv("READINESS_REQUIRE_ACCRUAL")); err == nil {
+		FlagReadinessRequireAccrual = envReadinessRequireAccrual
+	}
+	if envTracingExporter := os.Getenv("TRACING_EXPORTER"); envTracingExporter != "" {
+		FlagTracingExporter = envTracingExporter
+	}
+	if envTracingSampleRatio, err := strconv.ParseFloat(os.Getenv("TRACING_SAMPLE_RATIO"), 64); err == nil && envTracingSampleRatio >= 0 && envTracingSampleRatio <= 1 {
+		FlagTracingSampleRatio = envTracingSampleRatio
+	}
+	if envTracingTrustIncoming, err := strconv.ParseBool(os.Getenv("TRACING_TRUST_INCOMING")); err == nil {
+		FlagTracingTrustIncoming = envTracingTrustIncoming
+	}
+	if envNotifyWebhookURL := os.Getenv("NOTIFY_WEBHOOK_URL"); envNotifyWebhookURL != "" {
+		FlagNotifyWebhookURL = envNotifyWebhookURL
+	}
+	if envNotifyWebhookToken := os.Getenv("NOTIFY_WEBHOOK_TOKEN"); envNotifyWebhookToken != "" {
+		FlagNotifyWebhookToken = envNotifyWebhookToken
+	}
+	if envTOTPEncryptionKey := os.Getenv("TOTP_ENCRYPTION_KEY"); envTOTPEncryptionKey != "" {
+		FlagTOTPEncryptionKey = envTOTPEncryptionKey
+	}
+	if envAccountBalancePolicy := os.Getenv("ACCOUNT_BALANCE_POLICY"); envAccountBalancePolicy != "" {
+		FlagAccountBalancePolicy = envAccountBalancePolicy
+	}
+}
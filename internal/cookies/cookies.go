@@ -0,0 +1,89 @@
+// Пакет cookies задаёт общую политику атрибутов куки, которые выдаёт сервис.
+package cookies
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Имена куки
+const (
+	AccessToken  = "Authorization"
+	RefreshToken = "Refresh-Token"
+	CSRFToken    = "csrf_token"
+)
+
+// Заголовок, в котором клиент повторяет значение куки CSRFToken
+const CSRFHeader = "X-CSRF-Token"
+
+// Политика атрибутов куки
+type Policy struct {
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite
+	CSRF     bool // проверка CSRF-токена для запросов с авторизацией по куки
+}
+
+var policy = Policy{Secure: true, SameSite: http.SameSiteLaxMode, CSRF: true}
+
+// Настройка политики. Допустимые значения sameSite: lax, strict, none
+func Initialize(domain string, secure bool, sameSite string, csrf bool) error {
+	p := Policy{Domain: domain, Secure: secure, CSRF: csrf}
+
+	switch strings.ToLower(sameSite) {
+	case "", "lax":
+		p.SameSite = http.SameSiteLaxMode
+	case "strict":
+		p.SameSite = http.SameSiteStrictMode
+	case "none":
+		// Браузеры отклоняют куки SameSite=None без атрибута Secure
+		if !secure {
+			return fmt.Errorf("cookie SameSite=None requires Secure")
+		}
+		p.SameSite = http.SameSiteNoneMode
+	default:
+		return fmt.Errorf("unknown cookie SameSite mode %q", sameSite)
+	}
+
+	policy = p
+	return nil
+}
+
+// Включена ли проверка CSRF-токена
+func CSRFEnabled() bool {
+	return policy.CSRF
+}
+
+// Куки с атрибутами текущей политики
+func New(name, value, path string, expires time.Time, httpOnly bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   policy.Domain,
+		Expires:  expires,
+		Secure:   policy.Secure,
+		HttpOnly: httpOnly,
+		SameSite: policy.SameSite,
+	}
+}
+
+// Куки, удаляющая ранее выданную куки с тем же именем и путём
+func Expired(name, path string, httpOnly bool) *http.Cookie {
+	cookie := New(name, "", path, time.Time{}, httpOnly)
+	cookie.MaxAge = -1
+	return cookie
+}
+
+// Случайное значение CSRF-токена
+func NewCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,60 @@
+package cookies
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тестирование настройки политики куки
+func TestInitialize(t *testing.T) {
+	defer func(p Policy) { policy = p }(policy)
+
+	tests := []struct {
+		name     string
+		secure   bool
+		sameSite string
+		want     http.SameSite
+		wantErr  bool
+	}{
+		{name: "default", sameSite: "", want: http.SameSiteLaxMode},
+		{name: "strict", sameSite: "Strict", want: http.SameSiteStrictMode},
+		{name: "none with secure", secure: true, sameSite: "none", want: http.SameSiteNoneMode},
+		{name: "none without secure", sameSite: "none", wantErr: true},
+		{name: "unknown mode", sameSite: "sometimes", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Initialize("example.com", tt.secure, tt.sameSite, true)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			expires := time.Now().Add(time.Hour)
+			cookie := New(AccessToken, "token", "/", expires, true)
+			assert.Equal(t, "example.com", cookie.Domain)
+			assert.Equal(t, tt.secure, cookie.Secure)
+			assert.Equal(t, tt.want, cookie.SameSite)
+			assert.True(t, cookie.HttpOnly)
+			assert.Equal(t, expires, cookie.Expires)
+
+			expired := Expired(AccessToken, "/", true)
+			assert.Equal(t, -1, expired.MaxAge)
+			assert.Equal(t, "example.com", expired.Domain)
+		})
+	}
+}
+
+// Тестирование политики по умолчанию: куки только по HTTPS, проверка CSRF включена
+func TestDefaultPolicy(t *testing.T) {
+	cookie := New(AccessToken, "token", "/", time.Time{}, true)
+	assert.True(t, cookie.Secure)
+	assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+	assert.True(t, CSRFEnabled())
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/logger"
+	"github.com/dsemenov12/loyalty-gofermart/internal/middlewares/requestidhandler"
+	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/problem"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Политика остатка баланса при удалении учётной записи: forfeit или archive
+func (a *app) SetBalancePolicy(policy string) error {
+	switch policy {
+	case models.BalancePolicyForfeit, models.BalancePolicyArchive:
+		a.balancePolicy = policy
+		return nil
+	default:
+		return fmt.Errorf("unknown balance policy %q", policy)
+	}
+}
+
+// Удаление учётной записи. Требует пароль, а при включённой двухфакторной аутентификации и код
+func (a *app) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	var req models.DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+	if req.Password == "" {
+		problem.WriteValidation(w, r, http.StatusBadRequest, []problem.FieldError{
+			{Field: "password", Code: problem.FieldRequired, Message: "Password is required"},
+		})
+		return
+	}
+
+	userID, err := contextUserID(r)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	user, err := a.storage.GetUserByID(r.Context(), userID)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	// Подбор пароля ограничивается так же, как подбор при входе
+	loginKey, ipKey := loginAttemptKeys(r, user.Login, a.throttle.TrustProxyHeaders)
+	if !a.checkLoginLockout(w, r, loginKey, ipKey) {
+		return
+	}
+
+	valid := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)) == nil
+	if valid && user.TOTPEnabled {
+		if valid, err = a.checkSecondFactor(r.Context(), user.ID, req.Code, req.RecoveryCode); err != nil {
+			writeInternalError(w, r, err)
+			return
+		}
+	}
+	if !valid {
+		if err = a.registerLoginFailure(r.Context(), loginKey, ipKey); err != nil {
+			writeInternalError(w, r, err)
+			return
+		}
+		problem.Write(w, r, http.StatusForbidden, problem.CodeInvalidCredentials, "Invalid password or two-factor code")
+		return
+	}
+
+	if err = a.storage.DeleteUser(r.Context(), user.ID, a.balancePolicy); err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	clearSessionCookies(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Выгрузка данных пользователя: одним JSON-документом или ZIP-архивом из отдельных файлов
+func (a *app) ExportUserData(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "zip" {
+		problem.WriteValidation(w, r, http.StatusBadRequest, []problem.FieldError{
+			{Field: "format", Code: problem.FieldInvalid, Message: "Format must be json or zip"},
+		})
+		return
+	}
+
+	userID, err := contextUserID(r)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	export, err := a.collectUserData(r, userID)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	filename := fmt.Sprintf("gophermart-export-%d.%s", userID, format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(export)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.WriteHeader(http.StatusOK)
+	if err = writeExportZip(w, export); err != nil {
+		// Заголовки уже отправлены, клиент получит повреждённый архив
+		logger.Log.Error("export archive failed",
+			zap.String("request_id", requestidhandler.FromContext(r.Context())),
+			zap.Error(err),
+		)
+	}
+}
+
+// Сбор данных пользователя из хранилища
+func (a *app) collectUserData(r *http.Request, userID int) (*models.UserDataExport, error) {
+	ctx := r.Context()
+
+	user, err := a.storage.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := a.storage.GetBalanceByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := a.storage.GetOrdersByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	withdrawals, err := a.storage.GetUserWithdrawals(ctx)
+	if err != nil {
+		return nil, err
+	}
+	history, err := a.storage.GetBalanceHistory(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Пустые списки выгружаются как [], а не null
+	export := &models.UserDataExport{
+		ExportedAt:     time.Now().Format(time.RFC3339),
+		Profile:        newUserProfile(user),
+		Balance:        *balance,
+		Orders:         make([]models.OrderResponse, 0, len(orders)),
+		Withdrawals:    append(make([]models.Withdrawal, 0, len(withdrawals)), withdrawals...),
+		BalanceHistory: append(make([]models.Posting, 0, len(history)), history...),
+	}
+	for _, order := range orders {
+		export.Orders = append(export.Orders, newOrderResponse(order))
+	}
+
+	return export, nil
+}
+
+// ZIP-архив с отдельным файлом для каждого раздела выгрузки
+func writeExportZip(w http.ResponseWriter, export *models.UserDataExport) error {
+	archive := zip.NewWriter(w)
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", export.Profile},
+		{"balance.json", export.Balance},
+		{"orders.json", export.Orders},
+		{"withdrawals.json", export.Withdrawals},
+		{"balance_history.json", export.BalanceHistory},
+	}
+
+	for _, file := range files {
+		f, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(file.data); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
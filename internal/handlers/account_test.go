@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/auth"
+	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/money"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тестирование удаления учётной записи
+func Test_app_DeleteAccount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockStorage(ctrl)
+	m.EXPECT().GetUserByID(gomock.Any(), 1).Return(&models.User{ID: 1, Login: "user", Password: testPasswordHash}, nil).AnyTimes()
+	m.EXPECT().GetUserByID(gomock.Any(), 2).Return(&models.User{ID: 2, Login: "secure", Password: testPasswordHash, TOTPEnabled: true}, nil).AnyTimes()
+	m.EXPECT().GetLoginLockout(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+	m.EXPECT().GetTOTP(gomock.Any(), 2).Return(&models.TOTP{Secret: testTOTPSecret, Enabled: true}, nil).AnyTimes()
+	m.EXPECT().UseTOTPStep(gomock.Any(), 2, gomock.Any()).Return(nil)
+	m.EXPECT().RecordLoginFailure(gomock.Any(), gomock.Any(), gomock.Any()).Return(1, nil).Times(4)
+	m.EXPECT().DeleteUser(gomock.Any(), 1, models.BalancePolicyForfeit).Return(nil)
+	m.EXPECT().DeleteUser(gomock.Any(), 2, models.BalancePolicyForfeit).Return(nil)
+
+	app := NewApp(m)
+	require.NoError(t, app.SetBalancePolicy(models.BalancePolicyForfeit))
+	assert.Error(t, app.SetBalancePolicy("keep"))
+
+	tests := []struct {
+		name     string
+		userID   string
+		body     string
+		wantCode int
+	}{
+		{name: "missing password", userID: "1", body: `{}`, wantCode: http.StatusBadRequest},
+		{name: "wrong password", userID: "1", body: `{"password":"654321"}`, wantCode: http.StatusForbidden},
+		{name: "deleted", userID: "1", body: `{"password":"123456"}`, wantCode: http.StatusNoContent},
+		{name: "two-factor code required", userID: "2", body: `{"password":"123456"}`, wantCode: http.StatusForbidden},
+		{name: "deleted with two-factor code", userID: "2", body: `{"password":"123456","code":"` + currentTOTPCode(t) + `"}`, wantCode: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodDelete, "/api/user", strings.NewReader(tt.body))
+			request = request.WithContext(context.WithValue(request.Context(), auth.UserIDKey, tt.userID))
+			response := httptest.NewRecorder()
+
+			app.DeleteAccount(response, request)
+
+			assert.Equal(t, tt.wantCode, response.Code)
+			if tt.wantCode == http.StatusNoContent {
+				// Куки сессии удаляются
+				for _, cookie := range response.Result().Cookies() {
+					assert.Empty(t, cookie.Value)
+				}
+			}
+		})
+	}
+}
+
+// Тестирование выгрузки данных пользователя
+func Test_app_ExportUserData(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uploadedAt := time.Date(2025, 1, 8, 15, 15, 45, 0, time.UTC)
+
+	m := mocks.NewMockStorage(ctrl)
+	m.EXPECT().GetUserByID(gomock.Any(), 1).Return(&models.User{ID: 1, Login: "user", Password: testPasswordHash, Role: auth.RoleUser}, nil).AnyTimes()
+	m.EXPECT().GetBalanceByUserID(gomock.Any(), 1).Return(&models.Balance{Current: money.MustParse("500")}, nil).AnyTimes()
+	m.EXPECT().GetOrdersByUserID(gomock.Any(), 1).Return([]models.Order{
+		{UserID: 1, Number: "12345678903", Status: "PROCESSED", Accrual: money.MustParse("500"), UploadedAt: uploadedAt},
+	}, nil).AnyTimes()
+	m.EXPECT().GetUserWithdrawals(gomock.Any()).Return(nil, nil).AnyTimes()
+	m.EXPECT().GetBalanceHistory(gomock.Any()).Return([]models.Posting{
+		{Kind: models.PostingAccrual, Amount: money.MustParse("500"), Reference: "12345678903", ProcessedAt: uploadedAt.Format(time.RFC3339)},
+	}, nil).AnyTimes()
+
+	app := NewApp(m)
+
+	request := func(format string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "/api/user/export?format="+format, nil)
+		request = request.WithContext(context.WithValue(request.Context(), auth.UserIDKey, "1"))
+		response := httptest.NewRecorder()
+		app.ExportUserData(response, request)
+		return response
+	}
+
+	t.Run("json", func(t *testing.T) {
+		response := request("json")
+		require.Equal(t, http.StatusOK, response.Code)
+		assert.Contains(t, response.Header().Get("Content-Disposition"), "gophermart-export-1.json")
+
+		// Хеш пароля в выгрузку не попадает
+		assert.NotContains(t, response.Body.String(), testPasswordHash)
+
+		var export models.UserDataExport
+		require.NoError(t, json.NewDecoder(response.Body).Decode(&export))
+		assert.Equal(t, models.UserProfile{ID: 1, Login: "user", Role: auth.RoleUser}, export.Profile)
+		assert.Len(t, export.Orders, 1)
+		assert.NotNil(t, export.Withdrawals)
+		assert.Len(t, export.BalanceHistory, 1)
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		response := request("zip")
+		require.Equal(t, http.StatusOK, response.Code)
+		assert.Equal(t, "application/zip", response.Header().Get("Content-Type"))
+		assert.Contains(t, response.Header().Get("Content-Disposition"), "gophermart-export-1.zip")
+
+		body := response.Body.Bytes()
+		archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+		require.NoError(t, err)
+
+		contents := map[string]string{}
+		for _, file := range archive.File {
+			f, err := file.Open()
+			require.NoError(t, err)
+			data, err := io.ReadAll(f)
+			require.NoError(t, err)
+			f.Close()
+			contents[file.Name] = string(data)
+		}
+		// Каждый раздел выгрузки лежит в своём файле
+		assert.Len(t, contents, 5)
+		assert.JSONEq(t, `{"id":1,"login":"user","role":"user","two_factor_enabled":false}`, contents["profile.json"])
+		assert.JSONEq(t, `{"current":500,"withdrawn":0}`, contents["balance.json"])
+		assert.JSONEq(t, `[{"number":"12345678903","status":"PROCESSED","accrual":500,"uploaded_at":"2025-01-08T15:15:45Z"}]`, contents["orders.json"])
+		assert.JSONEq(t, `[]`, contents["withdrawals.json"])
+		assert.JSONEq(t, `[{"kind":"ACCRUAL","amount":500,"reference":"12345678903","processed_at":"2025-01-08T15:15:45Z"}]`, contents["balance_history.json"])
+
+		// Хеш пароля не попадает ни в один файл
+		for name, content := range contents {
+			assert.NotContains(t, content, testPasswordHash, name)
+		}
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, request("xml").Code)
+	})
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/logger"
+	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/problem"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// Поиск пользователя по логину
+func (a *app) AdminFindUser(w http.ResponseWriter, r *http.Request) {
+	login := r.URL.Query().Get("login")
+	if login == "" {
+		problem.WriteValidation(w, r, http.StatusBadRequest, []problem.FieldError{
+			{Field: "login", Code: problem.FieldRequired, Message: "Login is required"},
+		})
+		return
+	}
+
+	user, err := a.storage.GetUserByLogin(r.Context(), login)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	writeJSON(w, newUserProfile(user))
+}
+
+// Пользователь по идентификатору
+func (a *app) AdminGetUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.adminLookupUser(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, newUserProfile(user))
+}
+
+// Заказы пользователя. В отличие от API пользователя пустой список возвращается с кодом 200
+func (a *app) AdminGetUserOrders(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.adminLookupUser(w, r)
+	if !ok {
+		return
+	}
+
+	orders, err := a.storage.GetOrdersByUserID(r.Context(), user.ID)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	response := make([]models.OrderResponse, 0, len(orders))
+	for _, order := range orders {
+		response = append(response, newOrderResponse(order))
+	}
+
+	writeJSON(w, response)
+}
+
+// Баланс пользователя
+func (a *app) AdminGetUserBalance(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.adminLookupUser(w, r)
+	if !ok {
+		return
+	}
+
+	balance, err := a.storage.GetBalanceByUserID(r.Context(), user.ID)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	writeJSON(w, balance)
+}
+
+// Ручная корректировка баланса пользователя. Оператором считается владелец токена.
+// Повторный запрос с тем же Idempotency-Key возвращает исходную корректировку
+func (a *app) AdminAdjustBalance(w http.ResponseWriter, r *http.Request) {
+	var req models.BalanceAdjustmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if fieldErrors := validateBalanceAdjustment(req); len(fieldErrors) > 0 {
+		problem.WriteValidation(w, r, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	userID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid user ID")
+		return
+	}
+	operatorID, err := contextUserID(r)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	// Корректировку собственного баланса должен выполнить другой администратор
+	if operatorID == userID {
+		problem.Write(w, r, http.StatusForbidden, problem.CodeForbidden, "Cannot adjust own balance")
+		return
+	}
+
+	idempotencyKey := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		problem.WriteValidation(w, r, http.StatusBadRequest, []problem.FieldError{
+			{Field: "Idempotency-Key", Code: problem.FieldTooLong, Message: "Idempotency key is too long"},
+		})
+		return
+	}
+
+	adjustment, err := a.storage.AdjustBalance(r.Context(), models.BalanceAdjustment{
+		UserID:         userID,
+		OperatorID:     operatorID,
+		Amount:         req.Amount,
+		Reason:         req.Reason,
+		IdempotencyKey: idempotencyKey,
+	})
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	logger.Log.Info("balance adjusted",
+		zap.Int("adjustment_id", adjustment.ID),
+		zap.Int("user_id", adjustment.UserID),
+		zap.Int("operator_id", adjustment.OperatorID),
+		zap.String("amount", adjustment.Amount.String()),
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newBalanceAdjustmentResponse(*adjustment))
+}
+
+// Журнал корректировок баланса пользователя
+func (a *app) AdminGetBalanceAdjustments(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.adminLookupUser(w, r)
+	if !ok {
+		return
+	}
+
+	adjustments, err := a.storage.GetBalanceAdjustments(r.Context(), user.ID)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	response := make([]models.BalanceAdjustmentResponse, 0, len(adjustments))
+	for _, adjustment := range adjustments {
+		response = append(response, newBalanceAdjustmentResponse(adjustment))
+	}
+
+	writeJSON(w, response)
+}
+
+// Заказ по номеру вместе с загрузившим его пользователем
+func (a *app) AdminGetOrder(w http.ResponseWriter, r *http.Request) {
+	order, err := a.storage.GetOrderByNumber(r.Context(), chi.URLParam(r, "number"))
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	writeJSON(w, models.AdminOrderResponse{
+		UserID:        order.UserID,
+		OrderResponse: newOrderResponse(*order),
+	})
+}
+
+// Пользователь из параметра пути userID. При ошибке ответ уже отправлен
+func (a *app) adminLookupUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid user ID")
+		return nil, false
+	}
+
+	user, err := a.storage.GetUserByID(r.Context(), userID)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return nil, false
+	}
+
+	return user, true
+}
+
+func newUserProfile(user *models.User) models.UserProfile {
+	return models.UserProfile{
+		ID:               user.ID,
+		Login:            user.Login,
+		Role:             user.Role,
+		TwoFactorEnabled: user.TOTPEnabled,
+	}
+}
+
+func newBalanceAdjustmentResponse(adjustment models.BalanceAdjustment) models.BalanceAdjustmentResponse {
+	return models.BalanceAdjustmentResponse{
+		ID:         adjustment.ID,
+		UserID:     adjustment.UserID,
+		OperatorID: adjustment.OperatorID,
+		Amount:     adjustment.Amount,
+		Reason:     adjustment.Reason,
+		CreatedAt:  adjustment.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(v)
+}
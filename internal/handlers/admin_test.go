@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/auth"
+	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/money"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage/mocks"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тестирование API администратора
+func Test_app_Admin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uploadedAt := time.Date(2025, 1, 8, 15, 15, 45, 0, time.UTC)
+	user := &models.User{ID: 7, Login: "user", Role: auth.RoleUser, TOTPEnabled: true}
+
+	m := mocks.NewMockStorage(ctrl)
+	m.EXPECT().GetUserByLogin(gomock.Any(), "user").Return(user, nil)
+	m.EXPECT().GetUserByID(gomock.Any(), 7).Return(user, nil).Times(3)
+	m.EXPECT().GetUserByID(gomock.Any(), 8).Return(nil, storage.ErrUserNotFound)
+	m.EXPECT().GetOrdersByUserID(gomock.Any(), 7).Return(nil, nil)
+	m.EXPECT().GetBalanceByUserID(gomock.Any(), 7).Return(&models.Balance{Current: money.MustParse("500.5")}, nil)
+	m.EXPECT().GetOrderByNumber(gomock.Any(), "2377225624").Return(&models.Order{
+		UserID:     7,
+		Number:     "2377225624",
+		Status:     "PROCESSED",
+		Accrual:    money.MustParse("729.98"),
+		UploadedAt: uploadedAt,
+	}, nil)
+	m.EXPECT().GetOrderByNumber(gomock.Any(), "12345678903").Return(nil, storage.ErrOrderNotFound)
+
+	app := NewApp(m)
+	router := chi.NewRouter()
+	router.Get("/api/admin/users", app.AdminFindUser)
+	router.Get("/api/admin/users/{userID}", app.AdminGetUser)
+	router.Get("/api/admin/users/{userID}/orders", app.AdminGetUserOrders)
+	router.Get("/api/admin/users/{userID}/balance", app.AdminGetUserBalance)
+	router.Get("/api/admin/orders/{number}", app.AdminGetOrder)
+
+	tests := []struct {
+		name     string
+		url      string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "find user by login",
+			url:      "/api/admin/users?login=user",
+			wantCode: http.StatusOK,
+			wantBody: `{"id":7,"login":"user","role":"user","two_factor_enabled":true}`,
+		},
+		{name: "find user without login", url: "/api/admin/users", wantCode: http.StatusBadRequest},
+		{
+			name:     "get user",
+			url:      "/api/admin/users/7",
+			wantCode: http.StatusOK,
+			wantBody: `{"id":7,"login":"user","role":"user","two_factor_enabled":true}`,
+		},
+		{name: "unknown user", url: "/api/admin/users/8", wantCode: http.StatusNotFound},
+		{name: "invalid user id", url: "/api/admin/users/abc", wantCode: http.StatusBadRequest},
+		{name: "user without orders", url: "/api/admin/users/7/orders", wantCode: http.StatusOK, wantBody: `[]`},
+		{name: "user balance", url: "/api/admin/users/7/balance", wantCode: http.StatusOK, wantBody: `{"current":500.5,"withdrawn":0}`},
+		{
+			name:     "order by number",
+			url:      "/api/admin/orders/2377225624",
+			wantCode: http.StatusOK,
+			wantBody: `{"user_id":7,"number":"2377225624","status":"PROCESSED","accrual":729.98,"uploaded_at":"2025-01-08T15:15:45Z"}`,
+		},
+		{name: "unknown order", url: "/api/admin/orders/12345678903", wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			response := httptest.NewRecorder()
+
+			router.ServeHTTP(response, request)
+
+			assert.Equal(t, tt.wantCode, response.Code)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, response.Body.String())
+			}
+		})
+	}
+}
+
+// Тестирование роли в токене, выданном при входе
+func Test_app_UserLogin_Role(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockStorage(ctrl)
+	m.EXPECT().GetUserByLogin(gomock.Any(), "admin").Return(&models.User{ID: 1, Login: "admin", Password: testPasswordHash, Role: auth.RoleAdmin}, nil)
+	m.EXPECT().GetLoginLockout(gomock.Any(), gomock.Any(), gomock.Any())
+	m.EXPECT().ResetLoginFailures(gomock.Any(), gomock.Any())
+	m.EXPECT().CreateSession(gomock.Any(), gomock.Any(), gomock.Any())
+
+	app := NewApp(m)
+
+	request := httptest.NewRequest(http.MethodPost, "/api/user/login", strings.NewReader(`{"login":"admin","password":"123456"}`))
+	response := httptest.NewRecorder()
+	app.UserLogin(response, request)
+	require.Equal(t, http.StatusOK, response.Code)
+
+	var tokens models.TokenResponse
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&tokens))
+	claims, err := auth.ParseToken(tokens.Token)
+	require.NoError(t, err)
+	assert.Equal(t, auth.RoleAdmin, claims.Role)
+}
+
+// Тестирование ручной корректировки баланса
+func Test_app_AdminAdjustBalance(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	createdAt := time.Date(2025, 1, 8, 15, 15, 45, 0, time.UTC)
+
+	m := mocks.NewMockStorage(ctrl)
+	m.EXPECT().AdjustBalance(gomock.Any(), models.BalanceAdjustment{
+		UserID:     7,
+		OperatorID: 1,
+		Amount:     money.MustParse("150.25"),
+		Reason:     "Compensation for delayed order",
+	}).DoAndReturn(func(_ context.Context, adjustment models.BalanceAdjustment) (*models.BalanceAdjustment, error) {
+		adjustment.ID = 3
+		adjustment.CreatedAt = createdAt
+		return &adjustment, nil
+	})
+	m.EXPECT().AdjustBalance(gomock.Any(), models.BalanceAdjustment{
+		UserID:         7,
+		OperatorID:     1,
+		Amount:         money.MustParse("20"),
+		Reason:         "Bonus",
+		IdempotencyKey: "key-1",
+	}).Return(nil, storage.ErrIdempotencyKeyReused)
+	m.EXPECT().AdjustBalance(gomock.Any(), gomock.Any()).Return(nil, storage.ErrInsufficientFunds)
+	m.EXPECT().AdjustBalance(gomock.Any(), gomock.Any()).Return(nil, storage.ErrUserNotFound)
+
+	app := NewApp(m)
+	router := chi.NewRouter()
+	router.Post("/api/admin/users/{userID}/balance/adjustments", app.AdminAdjustBalance)
+
+	tests := []struct {
+		name           string
+		userID         string
+		body           string
+		idempotencyKey string
+		wantCode       int
+		wantBody       string
+	}{
+		{
+			name:     "credit",
+			userID:   "7",
+			body:     `{"amount": 150.25, "reason": " Compensation for delayed order "}`,
+			wantCode: http.StatusCreated,
+			wantBody: `{"id":3,"user_id":7,"operator_id":1,"amount":150.25,"reason":"Compensation for delayed order","created_at":"2025-01-08T15:15:45Z"}`,
+		},
+		{name: "idempotency key reused", userID: "7", body: `{"amount": 20, "reason": "Bonus"}`, idempotencyKey: "key-1", wantCode: http.StatusUnprocessableEntity},
+		{name: "debit exceeding balance", userID: "7", body: `{"amount": -1000, "reason": "Fraud"}`, wantCode: http.StatusPaymentRequired},
+		{name: "unknown user", userID: "8", body: `{"amount": 10, "reason": "Bonus"}`, wantCode: http.StatusNotFound},
+		{name: "zero amount", userID: "7", body: `{"amount": 0, "reason": "Bonus"}`, wantCode: http.StatusBadRequest},
+		{name: "missing reason", userID: "7", body: `{"amount": 10}`, wantCode: http.StatusBadRequest},
+		{name: "reason too long", userID: "7", body: `{"amount": 10, "reason": "` + strings.Repeat("a", 256) + `"}`, wantCode: http.StatusBadRequest},
+		{name: "invalid user id", userID: "abc", body: `{"amount": 10, "reason": "Bonus"}`, wantCode: http.StatusBadRequest},
+		{name: "own balance", userID: "1", body: `{"amount": 10, "reason": "Bonus"}`, wantCode: http.StatusForbidden},
+		{name: "idempotency key too long", userID: "7", body: `{"amount": 10, "reason": "Bonus"}`, idempotencyKey: strings.Repeat("k", 256), wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/api/admin/users/"+tt.userID+"/balance/adjustments", strings.NewReader(tt.body))
+			request = request.WithContext(context.WithValue(request.Context(), auth.UserIDKey, "1"))
+			if tt.idempotencyKey != "" {
+				request.Header.Set("Idempotency-Key", tt.idempotencyKey)
+			}
+			response := httptest.NewRecorder()
+
+			router.ServeHTTP(response, request)
+
+			assert.Equal(t, tt.wantCode, response.Code)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, response.Body.String())
+			}
+		})
+	}
+}
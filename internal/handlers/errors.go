@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/logger"
+	"github.com/dsemenov12/loyalty-gofermart/internal/middlewares/requestidhandler"
+	"github.com/dsemenov12/loyalty-gofermart/internal/problem"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage"
+	"go.uber.org/zap"
+)
+
+// Соответствие ошибки хранилища HTTP-ответу
+type errorMapping struct {
+	err     error
+	status  int
+	code    string
+	message string
+}
+
+// Ошибки хранилища, которые сообщаются клиенту. Остальные ошибки считаются внутренними
+var errorMappings = []errorMapping{
+	{err: storage.ErrUserExists, status: http.StatusConflict, code: problem.CodeUserExists, message: "User already exists"},
+	{err: storage.ErrUserNotFound, status: http.StatusNotFound, code: problem.CodeUserNotFound, message: "User not found"},
+	{err: storage.ErrOrderExistsForAnother, status: http.StatusConflict, code: problem.CodeOrderOwnedByAnother, message: "Order number already uploaded by another user"},
+	{err: storage.ErrOrderNotFound, status: http.StatusNotFound, code: problem.CodeOrderNotFound, message: "Order not found"},
+	{err: storage.ErrInsufficientFunds, status: http.StatusPaymentRequired, code: problem.CodeInsufficientFunds, message: "Insufficient funds"},
+	{err: storage.ErrOrderAlreadyWithdrawn, status: http.StatusUnprocessableEntity, code: problem.CodeOrderAlreadyWithdrawn, message: "Order number already used for withdrawal"},
+	{err: storage.ErrSessionNotFound, status: http.StatusUnauthorized, code: problem.CodeInvalidRefreshToken, message: "Invalid refresh token"},
+	{err: storage.ErrSessionRevoked, status: http.StatusUnauthorized, code: problem.CodeInvalidRefreshToken, message: "Refresh token expired or session revoked"},
+	{err: storage.ErrRefreshTokenReused, status: http.StatusUnauthorized, code: problem.CodeInvalidRefreshToken, message: "Refresh token reuse detected, session revoked"},
+	{err: storage.ErrResetTokenInvalid, status: http.StatusBadRequest, code: problem.CodeInvalidResetToken, message: "Password reset token is invalid or expired"},
+	{err: storage.ErrTOTPAlreadyEnabled, status: http.StatusConflict, code: problem.CodeTwoFactorEnabled, message: "Two-factor authentication is already enabled"},
+	{err: storage.ErrIdempotencyKeyReused, status: http.StatusUnprocessableEntity, code: problem.CodeIdempotencyKeyReused, message: "Idempotency key was used for another request"},
+}
+
+// Записывает ответ, соответствующий ошибке хранилища
+func writeStorageError(w http.ResponseWriter, r *http.Request, err error) {
+	for _, mapping := range errorMappings {
+		if errors.Is(err, mapping.err) {
+			problem.Write(w, r, mapping.status, mapping.code, mapping.message)
+			return
+		}
+	}
+
+	writeInternalError(w, r, err)
+}
+
+// Записывает ответ о внутренней ошибке, подробности попадают только в лог
+func writeInternalError(w http.ResponseWriter, r *http.Request, err error) {
+	logger.Log.Error("internal error",
+		zap.String("request_id", requestidhandler.FromContext(r.Context())),
+		zap.Error(err),
+	)
+	problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "Internal server error")
+}
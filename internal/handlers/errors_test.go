@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/problem"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// Тестирование соответствия ошибок хранилища HTTP-статусам
+func Test_writeStorageError(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantCode    int
+		wantProblem string
+	}{
+		{name: "user exists", err: storage.ErrUserExists, wantCode: http.StatusConflict, wantProblem: problem.CodeUserExists},
+		{name: "wrapped user exists", err: fmt.Errorf("%w: duplicate key", storage.ErrUserExists), wantCode: http.StatusConflict, wantProblem: problem.CodeUserExists},
+		{name: "order uploaded by another user", err: storage.ErrOrderExistsForAnother, wantCode: http.StatusConflict, wantProblem: problem.CodeOrderOwnedByAnother},
+		{name: "insufficient funds", err: storage.ErrInsufficientFunds, wantCode: http.StatusPaymentRequired, wantProblem: problem.CodeInsufficientFunds},
+		{name: "order already withdrawn", err: storage.ErrOrderAlreadyWithdrawn, wantCode: http.StatusUnprocessableEntity, wantProblem: problem.CodeOrderAlreadyWithdrawn},
+		{name: "error with the same text", err: errors.New("insufficient funds"), wantCode: http.StatusInternalServerError, wantProblem: problem.CodeInternal},
+		{name: "internal error", err: errors.New("connection refused"), wantCode: http.StatusInternalServerError, wantProblem: problem.CodeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/api/user/orders", nil)
+			response := httptest.NewRecorder()
+
+			writeStorageError(response, request, tt.err)
+
+			assert.Equal(t, tt.wantCode, response.Code)
+			assert.Equal(t, problem.ContentType, response.Header().Get("Content-Type"))
+
+			var body problem.Problem
+			assert.NoError(t, json.NewDecoder(response.Body).Decode(&body))
+			assert.Equal(t, tt.wantCode, body.Status)
+			assert.Equal(t, tt.wantProblem, body.Code)
+		})
+	}
+}
@@ -1,30 +1,38 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
-    "context"
 
 	"github.com/dsemenov12/loyalty-gofermart/internal/auth"
+	"github.com/dsemenov12/loyalty-gofermart/internal/cookies"
 	"github.com/dsemenov12/loyalty-gofermart/internal/helpers/luhn"
 	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/notify"
+	"github.com/dsemenov12/loyalty-gofermart/internal/problem"
 	"github.com/dsemenov12/loyalty-gofermart/internal/storage"
-    "github.com/dsemenov12/loyalty-gofermart/internal/accrual"
-	"github.com/dsemenov12/loyalty-gofermart/internal/config"
+	"github.com/dsemenov12/loyalty-gofermart/internal/validation"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type app struct {
-	storage storage.Storage
+	storage  storage.Storage
+	throttle LoginThrottle
+	// Доставка токенов сброса пароля. Без неё сброс пароля недоступен
+	sender notify.Sender
+	// Политика остатка баланса при удалении учётной записи
+	balancePolicy string
 }
 
 func NewApp(storage storage.Storage) *app {
-    return &app{storage: storage}
+    return &app{storage: storage, throttle: DefaultLoginThrottle, balancePolicy: models.BalancePolicyArchive}
 }
 
 // Регистрация пользователя
@@ -32,44 +40,48 @@ func (a *app) UserRegister(w http.ResponseWriter, r *http.Request) {
 	// Чтение и декодирование тела запроса
 	var req models.UserRegister
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid request body")
 		return
 	}
 
 	// Проверка обязательных полей
-	if req.Login == "" || req.Password == "" {
-		http.Error(w, "Login and password are required", http.StatusBadRequest)
+	if fieldErrors := validateCredentials(req); len(fieldErrors) > 0 {
+		problem.WriteValidation(w, r, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	// Проверка формата логина и стойкости пароля
+	if fieldErrors := validation.ValidateRegistration(req.Login, req.Password); len(fieldErrors) > 0 {
+		problem.WriteValidation(w, r, http.StatusBadRequest, fieldErrors)
 		return
 	}
 
 	// Хеширование пароля
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		http.Error(w, "Error hashing password", http.StatusInternalServerError)
+		writeInternalError(w, r, err)
 		return
 	}
 
 	// Сохранение пользователя в базе данных
 	err = a.storage.CreateUser(r.Context(), req.Login, string(hashedPassword))
 	if err != nil {
-		if err.Error() == "user already exists" {
-			http.Error(w, "User already exists", http.StatusConflict)
-		} else {
-			http.Error(w, "Error saving user", http.StatusInternalServerError)
-		}
+		writeStorageError(w, r, err)
 		return
 	}
 
 	// Получение данных пользователя из хранилища
 	user, err := a.storage.GetUserByLogin(r.Context(), req.Login)
 	if err != nil {
-		http.Error(w, "", http.StatusInternalServerError)
+		writeInternalError(w, r, err)
 		return
 	}
 
-	setCookieJWT(strconv.Itoa(user.ID), w)
-
-	w.WriteHeader(http.StatusOK)
+	// Токены выдаются в куки, заголовке Authorization и теле ответа
+	if err = a.startSession(r.Context(), w, user); err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
 }
 
 // Аутентификация пользователя
@@ -77,36 +89,61 @@ func (a *app) UserLogin(w http.ResponseWriter, r *http.Request) {
 	// Чтение и декодирование тела запроса
 	var req models.UserRegister
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid request body")
 		return
 	}
 
 	// Проверка обязательных полей
-	if req.Login == "" || req.Password == "" {
-		http.Error(w, "Login and password are required", http.StatusBadRequest)
+	if fieldErrors := validateCredentials(req); len(fieldErrors) > 0 {
+		problem.WriteValidation(w, r, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	// Вход заблокирован после серии неудачных попыток по логину или с адреса клиента
+	loginKey, ipKey := loginAttemptKeys(r, req.Login, a.throttle.TrustProxyHeaders)
+	if !a.checkLoginLockout(w, r, loginKey, ipKey) {
 		return
 	}
 
 	// Получение данных пользователя из хранилища
 	user, err := a.storage.GetUserByLogin(r.Context(), req.Login)
-	if err != nil {
-		if err.Error() == "user not found" {
-			http.Error(w, "Invalid login or password", http.StatusUnauthorized)
-		} else {
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+	if err != nil && !errors.Is(err, storage.ErrUserNotFound) {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	// Проверка пароля. Не сообщаем, существует ли пользователь с таким логином:
+	// для несуществующего пароль сравнивается с заглушкой за то же время
+	passwordHash := dummyPasswordHash
+	if user != nil {
+		passwordHash = user.Password
+	}
+	if bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(req.Password)) != nil || user == nil {
+		if err = a.registerLoginFailure(r.Context(), loginKey, ipKey); err != nil {
+			writeInternalError(w, r, err)
+			return
 		}
+		problem.Write(w, r, http.StatusUnauthorized, problem.CodeInvalidCredentials, "Invalid login or password")
 		return
 	}
 
-	// Проверка пароля
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		http.Error(w, "Invalid login or password", http.StatusUnauthorized)
+	// Токены выдаются только после проверки второго фактора
+	if user.TOTPEnabled {
+		a.writeTwoFactorChallenge(w, r, user.ID)
 		return
 	}
 
-	setCookieJWT(strconv.Itoa(user.ID), w)
+	// Счётчик по адресу не сбрасывается, иначе вход в свою учётную запись позволял бы продолжать перебор чужих
+	if err = a.storage.ResetLoginFailures(r.Context(), loginKey); err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
 
-	w.WriteHeader(http.StatusOK)
+	// Токены выдаются в куки, заголовке Authorization и теле ответа
+	if err = a.startSession(r.Context(), w, user); err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
 }
 
 // Обрабатывает загрузку номера заказа
@@ -114,34 +151,32 @@ func (a *app) UserUploadOrder(w http.ResponseWriter, r *http.Request) {
 	// Чтение тела запроса
 	body, err := io.ReadAll(r.Body)
 	if err != nil || len(body) == 0 {
-		http.Error(w, "Invalid request format", http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid request format")
 		return
 	}
 
 	// Преобразование и валидация номера заказа.
 	orderNumber := strings.TrimSpace(string(body))
 	if orderNumber == "" || !luhn.ValidateLuhn(orderNumber) {
-		http.Error(w, "Invalid order number format", http.StatusUnprocessableEntity)
+		problem.Write(w, r, http.StatusUnprocessableEntity, problem.CodeInvalidOrderNumber, "Invalid order number format")
 		return
 	}
 
 	// Проверка уникальности номера заказа.
 	status, err := a.storage.SaveOrder(r.Context(), orderNumber)
 	if err != nil {
-		if err.Error() == "order already exists for the same user" {
-			http.Error(w, "Order number already uploaded by this user", http.StatusOK)
-		} else if err.Error() == "order already exists for another user" {
-			http.Error(w, "Order number already uploaded by another user", http.StatusConflict)
-		} else {
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		// Повторная загрузка своего заказа не является ошибкой
+		if errors.Is(err, storage.ErrOrderExistsForUser) {
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintln(w, "Order number already uploaded by this user")
+			return
 		}
+		writeStorageError(w, r, err)
 		return
 	}
 
-	// Если номер принят в обработку
+	// Если номер принят в обработку, начисление рассчитает пул воркеров accrual.Worker
 	if status {
-		go a.checkOrderStatus(r.Context(), orderNumber)
-
 		w.WriteHeader(http.StatusAccepted)
 		fmt.Fprintln(w, "Order number accepted")
 	}
@@ -152,7 +187,7 @@ func (a *app) UserGetOrders(w http.ResponseWriter, r *http.Request) {
 	// Получение списка заказов из хранилища
 	orders, err := a.storage.GetOrdersByUser(r.Context())
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		writeStorageError(w, r, err)
 		return
 	}
 
@@ -165,20 +200,28 @@ func (a *app) UserGetOrders(w http.ResponseWriter, r *http.Request) {
 	// Преобразование данных в формат ответа
 	var response []models.OrderResponse
 	for _, order := range orders {
-		response = append(response, models.OrderResponse{
-			Number:     order.Number,
-			Status:     order.Status,
-			Accrual:    order.Accrual,
-			UploadedAt: order.UploadedAt.Format(time.RFC3339),
-		})
+		response = append(response, newOrderResponse(order))
 	}
 
 	// Отправляем ответ
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Преобразование заказа в формат ответа
+func newOrderResponse(order models.Order) models.OrderResponse {
+	item := models.OrderResponse{
+		Number:     order.Number,
+		Status:     order.Status,
+		UploadedAt: order.UploadedAt.Format(time.RFC3339),
 	}
+	// Начисление выводится только если оно есть
+	if !order.Accrual.IsZero() {
+		accrual := order.Accrual
+		item.Accrual = &accrual
+	}
+	return item
 }
 
 // Возвращает баланс пользователя
@@ -186,7 +229,7 @@ func (a *app) GetUserBalance(w http.ResponseWriter, r *http.Request) {
 	// Получение баланса
 	balance, err := a.storage.GetBalance(r.Context())
 	if err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		writeStorageError(w, r, err)
 		return
 	}
 
@@ -196,12 +239,32 @@ func (a *app) GetUserBalance(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(balance)
 }
 
+// История движений по счёту пользователя
+func (a *app) GetUserBalanceHistory(w http.ResponseWriter, r *http.Request) {
+	// Получение проводок из главной книги
+	history, err := a.storage.GetBalanceHistory(r.Context())
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	// Если движений нет
+	if len(history) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(history)
+}
+
 // Список списаний со счета пользователя
 func (a *app) GetUserWithdrawals(w http.ResponseWriter, r *http.Request) {
 	// Получение выводов средств из хранилища
 	withdrawals, err := a.storage.GetUserWithdrawals(r.Context())
 	if err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		writeStorageError(w, r, err)
 		return
 	}
 
@@ -217,42 +280,39 @@ func (a *app) GetUserWithdrawals(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(withdrawals)
 }
 
-// Списание средств со счета пользователя
+// Максимальная длина заголовка Idempotency-Key
+const maxIdempotencyKeyLength = 255
+
+// Списание средств со счета пользователя.
+// Повторный запрос с тем же Idempotency-Key возвращает результат исходного списания
 func (a *app) WithdrawUserBalance(w http.ResponseWriter, r *http.Request) {
 	// Парсинг тела запроса
 	var req models.WithdrawRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
-	if err != nil || req.Order == "" || req.Sum <= 0 {
-		http.Error(w, "Invalid request format", http.StatusUnprocessableEntity)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, http.StatusUnprocessableEntity, problem.CodeInvalidRequest, "Invalid request format")
 		return
 	}
 
-	// Получение баланса
-	balance, err := a.storage.GetBalance(r.Context())
-	if err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	// Валидация суммы и номера заказа, в счёт которого списываются баллы
+	req.Order = strings.TrimSpace(req.Order)
+	if fieldErrors := validateWithdrawal(req); len(fieldErrors) > 0 {
+		problem.WriteValidation(w, r, http.StatusUnprocessableEntity, fieldErrors)
 		return
 	}
 
-	// Проверка баланса
-	if balance.Current == 0 {
-		http.Error(w, "Balance is empty", http.StatusUnprocessableEntity)
+	// Ключ идемпотентности позволяет клиенту безопасно повторять запрос
+	idempotencyKey := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		problem.WriteValidation(w, r, http.StatusBadRequest, []problem.FieldError{
+			{Field: "Idempotency-Key", Code: problem.FieldTooLong, Message: "Idempotency key is too long"},
+		})
 		return
 	}
 
-	// Списание средств
-	err = a.storage.WithdrawUserBalance(r.Context(), req.Order, req.Sum)
+	// Списание средств. Достаточность баланса проверяется хранилищем под блокировкой
+	_, err := a.storage.WithdrawUserBalance(r.Context(), req.Order, req.Sum, idempotencyKey)
 	if err != nil {
-		switch {
-		case err.Error() == "invalid order number":
-			http.Error(w, "Invalid order number", http.StatusUnprocessableEntity)
-		case err.Error() == "insufficient funds":
-			http.Error(w, "Insufficient funds", http.StatusPaymentRequired)
-		case err.Error() == "balance not found":
-			http.Error(w, "Balance not found", http.StatusInternalServerError)
-		default:
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		}
+		writeStorageError(w, r, err)
 		return
 	}
 
@@ -260,60 +320,129 @@ func (a *app) WithdrawUserBalance(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-// Запись JWT в куки
-func setCookieJWT(userID string, w http.ResponseWriter) {
-	tokenString, err := auth.BuildJWTString(userID)
+// Завершение текущей сессии пользователя
+func (a *app) UserLogout(w http.ResponseWriter, r *http.Request) {
+	if sessionID, _ := r.Context().Value(auth.SessionIDKey).(string); sessionID != "" {
+		if err := a.storage.RevokeSession(r.Context(), sessionID); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+	}
+
+	clearSessionCookies(w)
+	w.WriteHeader(http.StatusOK)
+}
+
+// Обмен refresh-токена на новую пару токенов
+func (a *app) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	// Клиенты без куки передают refresh-токен в теле запроса
+	var presented string
+	if cookie, _ := r.Cookie(cookies.RefreshToken); cookie != nil {
+		presented = cookie.Value
+	} else {
+		var req models.RefreshTokenRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err == nil {
+			presented = req.RefreshToken
+		}
+	}
+	if presented == "" {
+		problem.Write(w, r, http.StatusUnauthorized, problem.CodeInvalidRefreshToken, "Refresh token required")
+		return
+	}
+
+	refreshToken, refreshTokenHash, err := auth.NewRefreshToken()
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		writeInternalError(w, r, err)
 		return
 	}
-    cookie := &http.Cookie{
-		Name: "Authorization",
-		Value: tokenString,
-		Expires: time.Now().Add(24 * time.Hour),
-		Path: "/",
+
+	// Предъявленный токен становится недействительным, взамен выдаётся новый
+	session, err := a.storage.RotateRefreshToken(r.Context(), auth.HashRefreshToken(presented), refreshTokenHash, time.Now().Add(auth.RefreshTokenExp))
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	if err = writeSessionTokens(w, *session, refreshToken); err != nil {
+		writeInternalError(w, r, err)
+		return
 	}
-		
-	http.SetCookie(w, cookie)
 }
 
-// Фоновая проверка статуса заказа
-func (a *app) checkOrderStatus(ctx context.Context, orderNumber string) {
-	client := accrual.NewClient(config.FlagAccrualSystemAddress)
+// Refresh-токен нужен только эндпоинтам обновления токена и выхода
+const refreshCookiePath = "/api/user"
 
-	for {
-		// Получаем информацию о начислениях
-		accrualInfo, err := client.GetAccrualInfo(orderNumber)
+// Создаёт сессию пользователя и отправляет клиенту её токены
+func (a *app) startSession(ctx context.Context, w http.ResponseWriter, user *models.User) error {
+	sessionID, err := auth.NewSessionID()
+	if err != nil {
+		return err
+	}
+	refreshToken, refreshTokenHash, err := auth.NewRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	session := models.Session{
+		ID:        sessionID,
+		UserID:    user.ID,
+		Role:      user.Role,
+		ExpiresAt: time.Now().Add(auth.RefreshTokenExp),
+	}
+	if err = a.storage.CreateSession(ctx, session, refreshTokenHash); err != nil {
+		return err
+	}
+
+	return writeSessionTokens(w, session, refreshToken)
+}
+
+// Отправка access- и refresh-токенов сессии: в куки для браузеров,
+// в заголовке Authorization и теле ответа для остальных клиентов
+func writeSessionTokens(w http.ResponseWriter, session models.Session, refreshToken string) error {
+	tokenString, err := setCookieJWT(strconv.Itoa(session.UserID), session.ID, session.Role, w)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, cookies.New(cookies.RefreshToken, refreshToken, refreshCookiePath, session.ExpiresAt, true))
+
+	// Значение CSRF-токена читает клиентский код, поэтому куки не HttpOnly
+	if cookies.CSRFEnabled() {
+		csrfToken, err := cookies.NewCSRFToken()
 		if err != nil {
-			if err.Error() == "order not found" {
-				// Если заказ не найден, завершаем процесс
-				return
-			}
-			if err.Error() == "too many requests" {
-				// Если превышено количество запросов, ждем перед повторной попыткой
-				time.Sleep(1 * time.Minute)
-				continue
-			}
-			// В случае других ошибок завершаем процесс
-			return
+			return err
 		}
+		http.SetCookie(w, cookies.New(cookies.CSRFToken, csrfToken, "/", session.ExpiresAt, false))
+	}
 
-		// Проверяем статус заказа
-		switch accrualInfo.Status {
-		case "PROCESSED", "INVALID":
-			// Если статус окончательный, обновляем в хранилище и завершаем
-			_ = a.storage.UpdateOrderStatus(ctx, orderNumber, accrualInfo.Status, accrualInfo.Accrual)
-            // Обновление баланса пользователя, если статус "PROCESSED"
-			if accrualInfo.Status == "PROCESSED" {
-				_ = a.storage.UpdateUserBalance(ctx, accrualInfo.Accrual)
-			}
-			return
-		case "PROCESSING", "REGISTERED":
-			// Если статус временный, продолжаем проверку через заданный интервал
-			time.Sleep(30 * time.Second)
-		default:
-			// Неизвестный статус, завершаем процесс
-			return
-		}
+	w.Header().Set("Authorization", "Bearer "+tokenString)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.TokenResponse{
+		Token:        tokenString,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(auth.TokenExp.Seconds()),
+		RefreshToken: refreshToken,
+	})
+
+	return nil
+}
+
+// Удаление куки сессии
+func clearSessionCookies(w http.ResponseWriter) {
+	http.SetCookie(w, cookies.Expired(cookies.AccessToken, "/", true))
+	http.SetCookie(w, cookies.Expired(cookies.RefreshToken, refreshCookiePath, true))
+	http.SetCookie(w, cookies.Expired(cookies.CSRFToken, "/", false))
+}
+
+// Запись JWT в куки, возвращает выпущенный токен. Срок куки совпадает со сроком токена
+func setCookieJWT(userID, sessionID, role string, w http.ResponseWriter) (string, error) {
+	tokenString, expiresAt, err := auth.IssueToken(userID, sessionID, role)
+	if err != nil {
+		return "", err
 	}
-}
\ No newline at end of file
+
+	http.SetCookie(w, cookies.New(cookies.AccessToken, tokenString, "/", expiresAt, true))
+
+	return tokenString, nil
+}
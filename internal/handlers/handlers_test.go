@@ -2,7 +2,7 @@ package handlers
 
 import (
 	"context"
-	"errors"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -10,7 +10,11 @@ import (
     "time"
 
     "github.com/dsemenov12/loyalty-gofermart/internal/auth"
+	"github.com/dsemenov12/loyalty-gofermart/internal/cookies"
 	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/money"
+	"github.com/dsemenov12/loyalty-gofermart/internal/problem"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage"
 	"github.com/dsemenov12/loyalty-gofermart/internal/storage/mocks"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
@@ -26,7 +30,8 @@ func Test_app_UserRegister(t *testing.T) {
 
 	m.EXPECT().GetUserByLogin(gomock.Any(), "user").Return(&models.User{ID: 1, Login: "user"}, nil).AnyTimes()
 	m.EXPECT().CreateUser(gomock.Any(), "user", gomock.Any()).AnyTimes()
-	m.EXPECT().CreateUser(gomock.Any(), "user1", gomock.Any()).Return(errors.New("user already exists")).AnyTimes()
+	m.EXPECT().CreateUser(gomock.Any(), "user1", gomock.Any()).Return(storage.ErrUserExists).AnyTimes()
+	m.EXPECT().CreateSession(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
 
 	// создадим экземпляр приложения и передадим ему «хранилище»
     app := NewApp(m)
@@ -42,7 +47,7 @@ func Test_app_UserRegister(t *testing.T) {
 	}{
 		{
             name: "positive test #1",
-			body: `{"login": "user","password": "123456"}`,
+			body: `{"login": "user","password": "12345678"}`,
             want: want{
                 code: http.StatusOK,
         		contentType: "application/json",
@@ -50,7 +55,7 @@ func Test_app_UserRegister(t *testing.T) {
         },
 		{
             name: "error conflict test",
-			body: `{"login": "user1","password": "123456"}`,
+			body: `{"login": "user1","password": "12345678"}`,
             want: want{
                 code: http.StatusConflict,
         		contentType: "application/json",
@@ -88,6 +93,49 @@ func Test_app_UserRegister(t *testing.T) {
 	}
 }
 
+// Тестирование ошибок валидации полей
+func Test_app_UserRegister_ValidationProblem(t *testing.T) {
+	app := NewApp(nil)
+
+	request := httptest.NewRequest(http.MethodPost, "/api/user/register", strings.NewReader(`{"login": "user"}`))
+	response := httptest.NewRecorder()
+
+	app.UserRegister(response, request)
+
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.Equal(t, problem.ContentType, response.Header().Get("Content-Type"))
+
+	var body problem.Problem
+	assert.NoError(t, json.NewDecoder(response.Body).Decode(&body))
+	assert.Equal(t, problem.CodeValidationFailed, body.Code)
+	assert.Equal(t, "/api/user/register", body.Instance)
+	assert.Equal(t, []problem.FieldError{
+		{Field: "password", Code: problem.FieldRequired, Message: "Password is required"},
+	}, body.Errors)
+}
+
+// Тестирование отклонения логина и пароля, не соответствующих политике
+func Test_app_UserRegister_PolicyProblem(t *testing.T) {
+	app := NewApp(nil)
+
+	request := httptest.NewRequest(http.MethodPost, "/api/user/register", strings.NewReader(`{"login": "user name", "password": "1234567"}`))
+	response := httptest.NewRecorder()
+
+	app.UserRegister(response, request)
+
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+
+	var body problem.Problem
+	assert.NoError(t, json.NewDecoder(response.Body).Decode(&body))
+	assert.Equal(t, problem.CodeValidationFailed, body.Code)
+	if assert.Len(t, body.Errors, 2) {
+		assert.Equal(t, "login", body.Errors[0].Field)
+		assert.Equal(t, problem.FieldInvalid, body.Errors[0].Code)
+		assert.Equal(t, "password", body.Errors[1].Field)
+		assert.Equal(t, problem.FieldTooShort, body.Errors[1].Code)
+	}
+}
+
 // Тестирование метода UserLogin
 func Test_app_UserLogin(t *testing.T) {
 	ctrl := gomock.NewController(t)
@@ -97,7 +145,11 @@ func Test_app_UserLogin(t *testing.T) {
 	m := mocks.NewMockStorage(ctrl)
 
 	m.EXPECT().GetUserByLogin(gomock.Any(), "user").Return(&models.User{ID: 1, Login: "user", Password: "$2a$10$dJQ76.hRXamJDPf.wYT/suWxZU0K25tvubpcXy8lW8X6ERzzBGQX2"}, nil).AnyTimes()
-	m.EXPECT().GetUserByLogin(gomock.Any(), "user1").Return(nil, errors.New("user not found")).AnyTimes()
+	m.EXPECT().GetUserByLogin(gomock.Any(), "user1").Return(nil, storage.ErrUserNotFound).AnyTimes()
+	m.EXPECT().CreateSession(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+	m.EXPECT().GetLoginLockout(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+	m.EXPECT().RecordLoginFailure(gomock.Any(), gomock.Any(), gomock.Any()).Return(1, nil).AnyTimes()
+	m.EXPECT().ResetLoginFailures(gomock.Any(), "login:user").AnyTimes()
 
 	// создадим экземпляр приложения и передадим ему «хранилище»
     app := NewApp(m)
@@ -251,7 +303,7 @@ func Test_app_GetUserBalance(t *testing.T) {
 	m := mocks.NewMockStorage(ctrl)
 	app := NewApp(m)
 
-	m.EXPECT().GetBalance(gomock.Any()).Return(&models.Balance{Current: 100.0}, nil).AnyTimes()
+	m.EXPECT().GetBalance(gomock.Any()).Return(&models.Balance{Current: money.MustParse("100")}, nil).AnyTimes()
 
 	tests := []struct {
 		name string
@@ -278,6 +330,51 @@ func Test_app_GetUserBalance(t *testing.T) {
 	}
 }
 
+// Тестирование метода GetUserBalanceHistory
+func Test_app_GetUserBalanceHistory(t *testing.T) {
+	tests := []struct {
+		name     string
+		history  []models.Posting
+		wantCode int
+	}{
+		{
+			name: "positive test",
+			history: []models.Posting{
+				{Kind: models.PostingAccrual, Amount: money.MustParse("500"), Reference: "12345678903", ProcessedAt: time.Now().Format(time.RFC3339)},
+				{Kind: models.PostingWithdrawal, Amount: money.MustParse("-100"), Reference: "2377225624", ProcessedAt: time.Now().Format(time.RFC3339)},
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "no content",
+			history:  nil,
+			wantCode: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			m := mocks.NewMockStorage(ctrl)
+			m.EXPECT().GetBalanceHistory(gomock.Any()).Return(tt.history, nil)
+
+			app := NewApp(m)
+
+			request := httptest.NewRequest(http.MethodGet, "/api/user/balance/history", nil)
+			response := httptest.NewRecorder()
+
+			app.GetUserBalanceHistory(response, request)
+
+			res := response.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.wantCode, res.StatusCode)
+		})
+	}
+}
+
 // Тестирование метода GetUserWithdrawals
 func Test_app_GetUserWithdrawals(t *testing.T) {
 	ctrl := gomock.NewController(t)
@@ -287,7 +384,7 @@ func Test_app_GetUserWithdrawals(t *testing.T) {
 	m := mocks.NewMockStorage(ctrl)
 
 	mockWithdrawals := []models.Withdrawal{
-		{Order: "123456789", Sum: 500, ProcessedAt: time.Now().Format(time.RFC3339)},
+		{Order: "123456789", Sum: money.MustParse("500"), ProcessedAt: time.Now().Format(time.RFC3339)},
 	}
 
 	m.EXPECT().GetUserWithdrawals(gomock.Any()).Return(mockWithdrawals, nil).AnyTimes()
@@ -332,26 +429,73 @@ func Test_app_WithdrawUserBalance(t *testing.T) {
 
 	m := mocks.NewMockStorage(ctrl)
 
-	m.EXPECT().WithdrawUserBalance(gomock.Any(), "123456789", float64(100)).Return(nil).AnyTimes()
-	m.EXPECT().WithdrawUserBalance(gomock.Any(), "123456789", float64(100)).Return(errors.New("insufficient funds")).AnyTimes()
-
-    m.EXPECT().GetBalance(gomock.Any()).Return(&models.Balance{Current: 100, Withdrawn: 0}, nil).AnyTimes()
-	m.EXPECT().GetBalance(gomock.Any()).Return(&models.Balance{Current: 0, Withdrawn: 0}, nil).AnyTimes()
+	m.EXPECT().WithdrawUserBalance(gomock.Any(), "2377225624", money.MustParse("100"), "").Return(true, nil).AnyTimes()
+	m.EXPECT().WithdrawUserBalance(gomock.Any(), "2377225624", money.MustParse("1000"), "").Return(false, storage.ErrInsufficientFunds).AnyTimes()
+	m.EXPECT().WithdrawUserBalance(gomock.Any(), "2377225624", money.MustParse("100"), "key-1").Return(true, nil).Times(2)
+	m.EXPECT().WithdrawUserBalance(gomock.Any(), "2377225624", money.MustParse("200"), "key-1").Return(false, storage.ErrIdempotencyKeyReused).AnyTimes()
+	m.EXPECT().WithdrawUserBalance(gomock.Any(), "12345678903", money.MustParse("100"), "").Return(false, storage.ErrOrderAlreadyWithdrawn).AnyTimes()
 
 	app := NewApp(m)
 
 	tests := []struct {
-		name     string
-		userID   int64
-		body     string
-		wantCode int
+		name           string
+		userID         int64
+		body           string
+		idempotencyKey string
+		wantCode       int
 	}{
 		{
 			name:     "positive test",
 			userID:   1,
-			body:     `{"order": "123456789", "sum": 100}`,
+			body:     `{"order": "2377225624", "sum": 100}`,
 			wantCode: http.StatusOK,
 		},
+		{
+			name:     "insufficient funds test",
+			userID:   1,
+			body:     `{"order": "2377225624", "sum": 1000}`,
+			wantCode: http.StatusPaymentRequired,
+		},
+		{
+			name:           "idempotent request test",
+			userID:         1,
+			body:           `{"order": "2377225624", "sum": 100}`,
+			idempotencyKey: "key-1",
+			wantCode:       http.StatusOK,
+		},
+		{
+			name:           "repeated idempotent request test",
+			userID:         1,
+			body:           `{"order": "2377225624", "sum": 100}`,
+			idempotencyKey: "key-1",
+			wantCode:       http.StatusOK,
+		},
+		{
+			name:           "idempotency key reuse test",
+			userID:         1,
+			body:           `{"order": "2377225624", "sum": 200}`,
+			idempotencyKey: "key-1",
+			wantCode:       http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "too long idempotency key test",
+			userID:         1,
+			body:           `{"order": "2377225624", "sum": 100}`,
+			idempotencyKey: strings.Repeat("k", 256),
+			wantCode:       http.StatusBadRequest,
+		},
+		{
+			name:     "invalid order number test",
+			userID:   1,
+			body:     `{"order": "123456789", "sum": 100}`,
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "order already used test",
+			userID:   1,
+			body:     `{"order": "12345678903", "sum": 100}`,
+			wantCode: http.StatusUnprocessableEntity,
+		},
 		{
 			name:     "empty body test",
 			userID:   1,
@@ -363,6 +507,9 @@ func Test_app_WithdrawUserBalance(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			request := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", strings.NewReader(tt.body))
+			if tt.idempotencyKey != "" {
+				request.Header.Set("Idempotency-Key", tt.idempotencyKey)
+			}
 			response := httptest.NewRecorder()
 
 			ctx := context.WithValue(request.Context(), auth.UserIDKey, tt.userID)
@@ -381,12 +528,14 @@ func Test_app_WithdrawUserBalance(t *testing.T) {
 // Тестирование метода setCookieJWT
 func Test_setCookieJWT(t *testing.T) {
 	tests := []struct {
-		name   string
-		userID string
+		name      string
+		userID    string
+		sessionID string
 	}{
 		{
-			name:   "set valid cookie",
-			userID: "1",
+			name:      "set valid cookie",
+			userID:    "1",
+			sessionID: "session1",
 		},
 		{
 			name:   "set empty user ID",
@@ -397,13 +546,158 @@ func Test_setCookieJWT(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			response := httptest.NewRecorder()
-			setCookieJWT(tt.userID, response)
+			tokenString, err := setCookieJWT(tt.userID, tt.sessionID, auth.RoleUser, response)
+			assert.NoError(t, err)
+			assert.NotEmpty(t, tokenString)
 
 			res := response.Result()
 			defer res.Body.Close()
 
 			cookie := res.Cookies()
-			assert.NotEmpty(t, cookie, "Expected cookie to be set")
+			if assert.Len(t, cookie, 1, "Expected cookie to be set") {
+				// Куки недоступна скриптам и истекает вместе с токеном
+				assert.True(t, cookie[0].HttpOnly)
+				assert.Equal(t, http.SameSiteLaxMode, cookie[0].SameSite)
+				assert.WithinDuration(t, time.Now().Add(auth.TokenExp), cookie[0].Expires, 2*time.Second)
+			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+// Тестирование создания сессии при входе
+func Test_app_UserLogin_Session(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockStorage(ctrl)
+	m.EXPECT().GetUserByLogin(gomock.Any(), "user").Return(&models.User{ID: 1, Login: "user", Password: "$2a$10$dJQ76.hRXamJDPf.wYT/suWxZU0K25tvubpcXy8lW8X6ERzzBGQX2"}, nil)
+
+	m.EXPECT().GetLoginLockout(gomock.Any(), "login:user", gomock.Any())
+	m.EXPECT().ResetLoginFailures(gomock.Any(), "login:user")
+
+	var refreshTokenHash string
+	m.EXPECT().CreateSession(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, session models.Session, hash string) error {
+			assert.Equal(t, 1, session.UserID)
+			assert.NotEmpty(t, session.ID)
+			refreshTokenHash = hash
+			return nil
+		},
+	)
+
+	app := NewApp(m)
+
+	request := httptest.NewRequest(http.MethodPost, "/api/user/login", strings.NewReader(`{"login":"user","password":"123456"}`))
+	response := httptest.NewRecorder()
+	app.UserLogin(response, request)
+
+	res := response.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	issued := map[string]*http.Cookie{}
+	for _, cookie := range res.Cookies() {
+		issued[cookie.Name] = cookie
+	}
+
+	// Access-токен содержит идентификатор сессии
+	claims, err := auth.ParseToken(issued[cookies.AccessToken].Value)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", claims.UserID)
+	assert.NotEmpty(t, claims.SessionID)
+
+	// В хранилище передаётся хеш выданного refresh-токена, а не сам токен
+	refreshCookie := issued[cookies.RefreshToken]
+	if assert.NotNil(t, refreshCookie) {
+		assert.True(t, refreshCookie.HttpOnly)
+		assert.Equal(t, auth.HashRefreshToken(refreshCookie.Value), refreshTokenHash)
+	}
+	// Для клиентов без куки токены дублируются в заголовке и теле ответа
+	var body models.TokenResponse
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+	assert.Equal(t, issued[cookies.AccessToken].Value, body.Token)
+	assert.Equal(t, "Bearer", body.TokenType)
+	assert.Equal(t, "Bearer "+body.Token, res.Header.Get("Authorization"))
+	if refreshCookie != nil {
+		assert.Equal(t, refreshCookie.Value, body.RefreshToken)
+	}
+}
+
+// Тестирование метода RefreshToken
+func Test_app_RefreshToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockStorage(ctrl)
+	m.EXPECT().RotateRefreshToken(gomock.Any(), auth.HashRefreshToken("valid"), gomock.Any(), gomock.Any()).
+		Return(&models.Session{ID: "session1", UserID: 1, ExpiresAt: time.Now().Add(time.Hour)}, nil).Times(2)
+	m.EXPECT().RotateRefreshToken(gomock.Any(), auth.HashRefreshToken("reused"), gomock.Any(), gomock.Any()).
+		Return(nil, storage.ErrRefreshTokenReused)
+	m.EXPECT().RotateRefreshToken(gomock.Any(), auth.HashRefreshToken("unknown"), gomock.Any(), gomock.Any()).
+		Return(nil, storage.ErrSessionNotFound)
+
+	app := NewApp(m)
+
+	tests := []struct {
+		name         string
+		refreshToken string
+		body         string
+		wantCode     int
+	}{
+		{name: "valid token", refreshToken: "valid", wantCode: http.StatusOK},
+		{name: "reused token", refreshToken: "reused", wantCode: http.StatusUnauthorized},
+		{name: "unknown token", refreshToken: "unknown", wantCode: http.StatusUnauthorized},
+		{name: "missing token", wantCode: http.StatusUnauthorized},
+		{name: "token in body", body: `{"refresh_token":"valid"}`, wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/api/user/token/refresh", strings.NewReader(tt.body))
+			if tt.refreshToken != "" {
+				request.AddCookie(&http.Cookie{Name: cookies.RefreshToken, Value: tt.refreshToken})
+			}
+			response := httptest.NewRecorder()
+
+			app.RefreshToken(response, request)
+
+			res := response.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.wantCode, res.StatusCode)
+			if tt.wantCode == http.StatusOK {
+				names := []string{}
+				for _, cookie := range res.Cookies() {
+					names = append(names, cookie.Name)
+				}
+				assert.ElementsMatch(t, []string{cookies.AccessToken, cookies.RefreshToken, cookies.CSRFToken}, names)
+			}
+		})
+	}
+}
+
+// Тестирование метода UserLogout
+func Test_app_UserLogout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockStorage(ctrl)
+	m.EXPECT().RevokeSession(gomock.Any(), "session1").Return(nil)
+
+	app := NewApp(m)
+
+	request := httptest.NewRequest(http.MethodPost, "/api/user/logout", nil)
+	ctx := context.WithValue(request.Context(), auth.UserIDKey, "1")
+	ctx = context.WithValue(ctx, auth.SessionIDKey, "session1")
+	response := httptest.NewRecorder()
+
+	app.UserLogout(response, request.WithContext(ctx))
+
+	res := response.Result()
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	for _, cookie := range res.Cookies() {
+		assert.Equal(t, -1, cookie.MaxAge)
+	}
+}
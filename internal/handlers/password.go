@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/auth"
+	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/notify"
+	"github.com/dsemenov12/loyalty-gofermart/internal/problem"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage"
+	"github.com/dsemenov12/loyalty-gofermart/internal/validation"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Настройка доставки токенов сброса пароля
+func (a *app) SetSender(sender notify.Sender) {
+	a.sender = sender
+}
+
+// Сброс пароля доступен, только если настроена доставка токенов
+func (a *app) PasswordResetEnabled() bool {
+	return a.sender != nil
+}
+
+// Смена пароля. Все сессии пользователя отзываются, для текущего клиента создаётся новая
+func (a *app) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	var fieldErrors []problem.FieldError
+	if req.OldPassword == "" {
+		fieldErrors = append(fieldErrors, problem.FieldError{Field: "old_password", Code: problem.FieldRequired, Message: "Current password is required"})
+	}
+	if req.NewPassword == "" {
+		fieldErrors = append(fieldErrors, problem.FieldError{Field: "new_password", Code: problem.FieldRequired, Message: "New password is required"})
+	}
+	if len(fieldErrors) > 0 {
+		problem.WriteValidation(w, r, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	userID, err := contextUserID(r)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	user, err := a.storage.GetUserByID(r.Context(), userID)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	// Подбор текущего пароля ограничивается так же, как подбор при входе
+	loginKey, ipKey := loginAttemptKeys(r, user.Login, a.throttle.TrustProxyHeaders)
+	if !a.checkLoginLockout(w, r, loginKey, ipKey) {
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.OldPassword)) != nil {
+		if err = a.registerLoginFailure(r.Context(), loginKey, ipKey); err != nil {
+			writeInternalError(w, r, err)
+			return
+		}
+		problem.Write(w, r, http.StatusForbidden, problem.CodeInvalidCredentials, "Current password is incorrect")
+		return
+	}
+
+	if fieldErrors := renameField(validation.ValidatePassword(user.Login, req.NewPassword), "new_password"); len(fieldErrors) > 0 {
+		problem.WriteValidation(w, r, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	if err = a.storage.UpdatePassword(r.Context(), user.ID, string(hashedPassword)); err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	if err = a.startSession(r.Context(), w, user); err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+}
+
+// Запрос сброса пароля. Ответ не зависит от того, существует ли пользователь.
+// Каждый запрос учитывается как попытка, чтобы сброс нельзя было использовать
+// для рассылки сообщений пользователю или перебора логинов
+func (a *app) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req models.PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+	if req.Login == "" {
+		problem.WriteValidation(w, r, http.StatusBadRequest, []problem.FieldError{
+			{Field: "login", Code: problem.FieldRequired, Message: "Login is required"},
+		})
+		return
+	}
+
+	loginKey, ipKey := resetAttemptKeys(r, req.Login, a.throttle.TrustProxyHeaders)
+	if !a.checkLoginLockout(w, r, loginKey, ipKey) {
+		return
+	}
+	if err := a.registerLoginFailure(r.Context(), loginKey, ipKey); err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	user, err := a.storage.GetUserByLogin(r.Context(), req.Login)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	token, tokenHash, err := auth.NewPasswordResetToken()
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	if err = a.storage.CreatePasswordResetToken(r.Context(), user.ID, tokenHash, auth.PasswordResetTokenExp); err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	if err = a.sender.SendPasswordReset(r.Context(), user.Login, token); err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Установка нового пароля по токену сброса
+func (a *app) ConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req models.PasswordResetConfirm
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	var fieldErrors []problem.FieldError
+	if req.Token == "" {
+		fieldErrors = append(fieldErrors, problem.FieldError{Field: "token", Code: problem.FieldRequired, Message: "Reset token is required"})
+	}
+	if req.Password == "" {
+		fieldErrors = append(fieldErrors, problem.FieldError{Field: "password", Code: problem.FieldRequired, Message: "Password is required"})
+	}
+	if len(fieldErrors) > 0 {
+		problem.WriteValidation(w, r, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	// Пароль проверяется с учётом логина владельца токена, как при регистрации и смене пароля
+	user, err := a.storage.GetPasswordResetUser(r.Context(), auth.HashPasswordResetToken(req.Token))
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	if fieldErrors := validation.ValidatePassword(user.Login, req.Password); len(fieldErrors) > 0 {
+		problem.WriteValidation(w, r, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	// Токен погашается, все сессии пользователя отзываются
+	err = a.storage.ResetPassword(r.Context(), auth.HashPasswordResetToken(req.Token), string(hashedPassword))
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Идентификатор пользователя, сохранённый в контексте запроса
+func contextUserID(r *http.Request) (int, error) {
+	value, ok := r.Context().Value(auth.UserIDKey).(string)
+	if !ok {
+		return 0, errors.New("user id not found in context")
+	}
+	return strconv.Atoi(value)
+}
+
+// Переименование поля в ошибках валидации, общих для нескольких запросов
+func renameField(fieldErrors []problem.FieldError, field string) []problem.FieldError {
+	for i := range fieldErrors {
+		fieldErrors[i].Field = field
+	}
+	return fieldErrors
+}
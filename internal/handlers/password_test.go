@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/auth"
+	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Хеш пароля 123456
+const testPasswordHash = "$2a$10$dJQ76.hRXamJDPf.wYT/suWxZU0K25tvubpcXy8lW8X6ERzzBGQX2"
+
+// Отправитель, запоминающий токены сброса пароля
+type recordingSender struct {
+	tokens map[string]string
+}
+
+func (s *recordingSender) SendPasswordReset(ctx context.Context, login, token string) error {
+	s.tokens[login] = token
+	return nil
+}
+
+// Тестирование метода ChangePassword
+func Test_app_ChangePassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockStorage(ctrl)
+	m.EXPECT().GetUserByID(gomock.Any(), 1).Return(&models.User{ID: 1, Login: "user", Password: testPasswordHash}, nil).AnyTimes()
+	m.EXPECT().GetLoginLockout(gomock.Any(), "login:user", gomock.Any()).AnyTimes()
+	m.EXPECT().RecordLoginFailure(gomock.Any(), gomock.Any(), gomock.Any()).Return(1, nil).Times(2)
+	m.EXPECT().UpdatePassword(gomock.Any(), 1, gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ int, hashedPassword string) error {
+			assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte("new-password")))
+			return nil
+		},
+	)
+	m.EXPECT().CreateSession(gomock.Any(), gomock.Any(), gomock.Any())
+
+	app := NewApp(m)
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{name: "password changed", body: `{"old_password":"123456","new_password":"new-password"}`, wantCode: http.StatusOK},
+		{name: "wrong current password", body: `{"old_password":"654321","new_password":"new-password"}`, wantCode: http.StatusForbidden},
+		{name: "weak new password", body: `{"old_password":"123456","new_password":"123"}`, wantCode: http.StatusBadRequest},
+		{name: "missing fields", body: `{}`, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/api/user/password", strings.NewReader(tt.body))
+			request = request.WithContext(context.WithValue(request.Context(), auth.UserIDKey, "1"))
+			response := httptest.NewRecorder()
+
+			app.ChangePassword(response, request)
+
+			res := response.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.wantCode, res.StatusCode)
+		})
+	}
+}
+
+// Тестирование запроса и подтверждения сброса пароля
+func Test_app_PasswordReset(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockStorage(ctrl)
+	m.EXPECT().GetLoginLockout(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+	m.EXPECT().RecordLoginFailure(gomock.Any(), gomock.Any(), gomock.Any()).Return(1, nil).AnyTimes()
+	m.EXPECT().GetUserByLogin(gomock.Any(), "username").Return(&models.User{ID: 1, Login: "username"}, nil)
+	m.EXPECT().GetUserByLogin(gomock.Any(), "unknown").Return(nil, storage.ErrUserNotFound)
+
+	var storedHash string
+	m.EXPECT().CreatePasswordResetToken(gomock.Any(), 1, gomock.Any(), auth.PasswordResetTokenExp).DoAndReturn(
+		func(_ context.Context, _ int, tokenHash string, _ interface{}) error {
+			storedHash = tokenHash
+			return nil
+		},
+	)
+
+	// Без доставки токенов сброс пароля недоступен
+	app := NewApp(m)
+	assert.False(t, app.PasswordResetEnabled())
+
+	sender := &recordingSender{tokens: map[string]string{}}
+	app.SetSender(sender)
+	assert.True(t, app.PasswordResetEnabled())
+
+	// Ответ одинаков для существующего и несуществующего пользователя
+	for _, login := range []string{"username", "unknown"} {
+		request := httptest.NewRequest(http.MethodPost, "/api/user/password/reset/request", strings.NewReader(`{"login":"`+login+`"}`))
+		response := httptest.NewRecorder()
+		app.RequestPasswordReset(response, request)
+		assert.Equal(t, http.StatusAccepted, response.Code)
+	}
+
+	// Пользователю отправляется токен, в хранилище попадает только его хеш
+	token := sender.tokens["username"]
+	assert.NotEmpty(t, token)
+	assert.NotContains(t, sender.tokens, "unknown")
+	assert.Equal(t, auth.HashPasswordResetToken(token), storedHash)
+
+	m.EXPECT().GetPasswordResetUser(gomock.Any(), storedHash).Return(&models.User{ID: 1, Login: "username"}, nil).AnyTimes()
+	m.EXPECT().GetPasswordResetUser(gomock.Any(), auth.HashPasswordResetToken("used")).Return(nil, storage.ErrResetTokenInvalid)
+	m.EXPECT().ResetPassword(gomock.Any(), storedHash, gomock.Any()).Return(nil)
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{name: "valid token", body: `{"token":"` + token + `","password":"new-password"}`, wantCode: http.StatusOK},
+		{name: "used token", body: `{"token":"used","password":"new-password"}`, wantCode: http.StatusBadRequest},
+		{name: "weak password", body: `{"token":"` + token + `","password":"123"}`, wantCode: http.StatusBadRequest},
+		{name: "password matches login", body: `{"token":"` + token + `","password":"USERNAME"}`, wantCode: http.StatusBadRequest},
+		{name: "missing fields", body: `{}`, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/api/user/password/reset/confirm", strings.NewReader(tt.body))
+			response := httptest.NewRecorder()
+
+			app.ConfirmPasswordReset(response, request)
+
+			assert.Equal(t, tt.wantCode, response.Code)
+		})
+	}
+}
+
+// Тестирование ограничения частоты запросов сброса пароля
+func Test_app_RequestPasswordReset_Throttle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockStorage(ctrl)
+	// Запросы сброса учитываются отдельно от попыток входа
+	m.EXPECT().GetLoginLockout(gomock.Any(), "reset:login:user", "reset:ip:192.0.2.1").Return(time.Duration(0), nil)
+	m.EXPECT().RecordLoginFailure(gomock.Any(), "reset:login:user", gomock.Any()).Return(5, nil)
+	m.EXPECT().RecordLoginFailure(gomock.Any(), "reset:ip:192.0.2.1", gomock.Any()).Return(5, nil)
+	m.EXPECT().LockLogin(gomock.Any(), "reset:login:user", time.Minute)
+	m.EXPECT().GetUserByLogin(gomock.Any(), "user").Return(&models.User{ID: 1, Login: "user"}, nil)
+	m.EXPECT().CreatePasswordResetToken(gomock.Any(), 1, gomock.Any(), auth.PasswordResetTokenExp)
+
+	// После блокировки токен не выдаётся и сообщение не отправляется
+	m.EXPECT().GetLoginLockout(gomock.Any(), "reset:login:user", "reset:ip:192.0.2.1").Return(time.Minute, nil)
+
+	app := NewApp(m)
+	sender := &recordingSender{tokens: map[string]string{}}
+	app.SetSender(sender)
+
+	for _, wantCode := range []int{http.StatusAccepted, http.StatusTooManyRequests} {
+		request := httptest.NewRequest(http.MethodPost, "/api/user/password/reset/request", strings.NewReader(`{"login":"user"}`))
+		request.RemoteAddr = "192.0.2.1:1234"
+		response := httptest.NewRecorder()
+
+		app.RequestPasswordReset(response, request)
+
+		assert.Equal(t, wantCode, response.Code)
+	}
+	assert.Len(t, sender.tokens, 1)
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/helpers/backoff"
+	"github.com/dsemenov12/loyalty-gofermart/internal/problem"
+)
+
+// Защита входа от перебора паролей
+type LoginThrottle struct {
+	Login backoff.Policy // блокировка по логину
+	IP    backoff.Policy // блокировка по адресу клиента; порог выше, так как за одним адресом может быть много пользователей
+	// Через это время после последней неудачи счётчик попыток начинается заново
+	Window time.Duration
+	// Брать адрес клиента из X-Forwarded-For и X-Real-IP. Включается только за доверенным прокси
+	TrustProxyHeaders bool
+}
+
+var DefaultLoginThrottle = LoginThrottle{
+	Login:  backoff.Policy{Threshold: 5, Base: time.Minute, Max: time.Hour},
+	IP:     backoff.Policy{Threshold: 20, Base: time.Minute, Max: time.Hour},
+	Window: 24 * time.Hour,
+}
+
+// Хеш случайного пароля с той же стоимостью bcrypt, что и у паролей пользователей. С ним сравнивается
+// пароль при входе под несуществующим логином, чтобы время ответа не выдавало, есть ли такой пользователь
+const dummyPasswordHash = "$2a$10$MwmAj/NYa3IKyTb344fJtugelfTa9aidP7ztdAMOXlpFtvRUIPABa"
+
+// Настройка защиты входа от перебора паролей
+func (a *app) SetLoginThrottle(throttle LoginThrottle) {
+	a.throttle = throttle
+}
+
+// Ключи учёта попыток входа
+func loginAttemptKeys(r *http.Request, login string, trustProxyHeaders bool) (loginKey, ipKey string) {
+	return "login:" + strings.ToLower(login), "ip:" + clientIP(r, trustProxyHeaders)
+}
+
+// Ключи учёта запросов сброса пароля. Счётчики отделены от счётчиков входа,
+// чтобы запросы сброса не блокировали вход владельцу учётной записи
+func resetAttemptKeys(r *http.Request, login string, trustProxyHeaders bool) (loginKey, ipKey string) {
+	loginKey, ipKey = loginAttemptKeys(r, login, trustProxyHeaders)
+	return "reset:" + loginKey, "reset:" + ipKey
+}
+
+// Проверяет блокировку входа. При блокировке отвечает 429 и возвращает false
+func (a *app) checkLoginLockout(w http.ResponseWriter, r *http.Request, keys ...string) bool {
+	wait, err := a.storage.GetLoginLockout(r.Context(), keys...)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return false
+	}
+	if wait > 0 {
+		writeTooManyAttempts(w, r, wait)
+		return false
+	}
+	return true
+}
+
+// Учитывает неудачную попытку входа и при превышении порога блокирует вход
+func (a *app) registerLoginFailure(ctx context.Context, loginKey, ipKey string) error {
+	for _, item := range []struct {
+		key    string
+		policy backoff.Policy
+	}{
+		{key: loginKey, policy: a.throttle.Login},
+		{key: ipKey, policy: a.throttle.IP},
+	} {
+		failures, err := a.storage.RecordLoginFailure(ctx, item.key, a.throttle.Window)
+		if err != nil {
+			return err
+		}
+		if delay := item.policy.Delay(failures); delay > 0 {
+			if err = a.storage.LockLogin(ctx, item.key, delay); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Ответ 429 с заголовком Retry-After в целых секундах
+func writeTooManyAttempts(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	problem.Write(w, r, http.StatusTooManyRequests, problem.CodeTooManyLoginAttempts, "Too many failed login attempts, try again later")
+}
+
+// Адрес клиента
+func clientIP(r *http.Request, trustProxyHeaders bool) string {
+	if trustProxyHeaders {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			if ip := strings.TrimSpace(first); ip != "" {
+				return ip
+			}
+		}
+		if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+			return realIP
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Тестирование блокировки входа после серии неудачных попыток
+func Test_app_UserLogin_Throttle(t *testing.T) {
+	const passwordHash = "$2a$10$dJQ76.hRXamJDPf.wYT/suWxZU0K25tvubpcXy8lW8X6ERzzBGQX2"
+
+	login := func(app *app, body string) *http.Response {
+		request := httptest.NewRequest(http.MethodPost, "/api/user/login", strings.NewReader(body))
+		request.RemoteAddr = "203.0.113.7:51234"
+		response := httptest.NewRecorder()
+		app.UserLogin(response, request)
+		return response.Result()
+	}
+
+	t.Run("locked out", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		m := mocks.NewMockStorage(ctrl)
+		m.EXPECT().GetLoginLockout(gomock.Any(), "login:user", "ip:203.0.113.7").Return(90*time.Second+time.Millisecond, nil)
+
+		// Пароль не проверяется, пока вход заблокирован
+		res := login(NewApp(m), `{"login":"User","password":"123456"}`)
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+		assert.Equal(t, "91", res.Header.Get("Retry-After"))
+	})
+
+	t.Run("lock after threshold", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		m := mocks.NewMockStorage(ctrl)
+		m.EXPECT().GetLoginLockout(gomock.Any(), gomock.Any(), gomock.Any())
+		m.EXPECT().GetUserByLogin(gomock.Any(), "user").Return(&models.User{ID: 1, Login: "user", Password: passwordHash}, nil)
+		m.EXPECT().RecordLoginFailure(gomock.Any(), "login:user", DefaultLoginThrottle.Window).Return(DefaultLoginThrottle.Login.Threshold+1, nil)
+		m.EXPECT().LockLogin(gomock.Any(), "login:user", 2*DefaultLoginThrottle.Login.Base)
+		m.EXPECT().RecordLoginFailure(gomock.Any(), "ip:203.0.113.7", DefaultLoginThrottle.Window).Return(DefaultLoginThrottle.Login.Threshold+1, nil)
+
+		res := login(NewApp(m), `{"login":"user","password":"wrong"}`)
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+
+	t.Run("unknown login", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		m := mocks.NewMockStorage(ctrl)
+		m.EXPECT().GetLoginLockout(gomock.Any(), gomock.Any(), gomock.Any())
+		m.EXPECT().GetUserByLogin(gomock.Any(), "unknown").Return(nil, storage.ErrUserNotFound)
+		m.EXPECT().RecordLoginFailure(gomock.Any(), "login:unknown", DefaultLoginThrottle.Window).Return(1, nil)
+		m.EXPECT().RecordLoginFailure(gomock.Any(), "ip:203.0.113.7", DefaultLoginThrottle.Window).Return(1, nil)
+
+		res := login(NewApp(m), `{"login":"unknown","password":"123456"}`)
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+}
+
+// Заглушка хеша должна проверяться так же долго, как пароли пользователей
+func Test_dummyPasswordHash(t *testing.T) {
+	cost, err := bcrypt.Cost([]byte(dummyPasswordHash))
+	assert.NoError(t, err)
+	assert.Equal(t, bcrypt.DefaultCost, cost)
+}
+
+// Тестирование определения адреса клиента
+func Test_clientIP(t *testing.T) {
+	request := httptest.NewRequest(http.MethodPost, "/api/user/login", nil)
+	request.RemoteAddr = "10.0.0.1:40000"
+	request.Header.Set("X-Forwarded-For", "198.51.100.1, 10.0.0.1")
+
+	// Заголовки прокси подделываются клиентом, если им не доверять явно
+	assert.Equal(t, "10.0.0.1", clientIP(request, false))
+	assert.Equal(t, "198.51.100.1", clientIP(request, true))
+
+	request.Header.Del("X-Forwarded-For")
+	request.Header.Set("X-Real-IP", "198.51.100.2")
+	assert.Equal(t, "198.51.100.2", clientIP(request, true))
+}
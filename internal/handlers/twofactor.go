@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/auth"
+	"github.com/dsemenov12/loyalty-gofermart/internal/helpers/totp"
+	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/problem"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Название сервиса в приложении-аутентификаторе
+const totpIssuer = "Gophermart"
+
+// Число кодов восстановления, выдаваемых при включении
+const recoveryCodesCount = 10
+
+// Начало настройки двухфакторной аутентификации: выдаёт секрет, который нужно подтвердить кодом.
+// Секрет хранится зашифрованным, поэтому без ключа шифрования настройка недоступна
+func (a *app) SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if !auth.TOTPKeyConfigured() {
+		problem.Write(w, r, http.StatusServiceUnavailable, problem.CodeTwoFactorUnavailable, "Two-factor authentication is not configured on the server")
+		return
+	}
+
+	userID, err := contextUserID(r)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	user, err := a.storage.GetUserByID(r.Context(), userID)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	sealedSecret, err := auth.SealTOTPSecret(user.ID, secret)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	if err = a.storage.SetTOTPSecret(r.Context(), user.ID, sealedSecret); err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.TwoFactorSetupResponse{
+		Secret: secret,
+		URI:    totp.URI(totpIssuer, user.Login, secret),
+	})
+}
+
+// Подтверждение настройки кодом из приложения. Включает двухфакторную аутентификацию
+// и возвращает коды восстановления, которые больше нигде не показываются
+func (a *app) VerifyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req models.TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+	if req.Code == "" {
+		problem.WriteValidation(w, r, http.StatusBadRequest, []problem.FieldError{
+			{Field: "code", Code: problem.FieldRequired, Message: "Code is required"},
+		})
+		return
+	}
+
+	userID, err := contextUserID(r)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	user, err := a.storage.GetUserByID(r.Context(), userID)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	// Подбор кода ограничивается так же, как подбор при входе
+	loginKey, ipKey := loginAttemptKeys(r, user.Login, a.throttle.TrustProxyHeaders)
+	if !a.checkLoginLockout(w, r, loginKey, ipKey) {
+		return
+	}
+
+	settings, err := a.storage.GetTOTP(r.Context(), userID)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	if settings.Enabled {
+		writeStorageError(w, r, storage.ErrTOTPAlreadyEnabled)
+		return
+	}
+	if settings.Secret == "" {
+		problem.Write(w, r, http.StatusConflict, problem.CodeTwoFactorNotEnabled, "Two-factor setup has not been started")
+		return
+	}
+
+	secret, err := auth.OpenTOTPSecret(userID, settings.Secret)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	step, ok := totp.Validate(secret, req.Code, time.Now())
+	if !ok {
+		if err = a.registerLoginFailure(r.Context(), loginKey, ipKey); err != nil {
+			writeInternalError(w, r, err)
+			return
+		}
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidTwoFactorCode, "Invalid two-factor code")
+		return
+	}
+
+	codes, hashes, err := auth.NewRecoveryCodes(recoveryCodesCount)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	if err = a.storage.EnableTOTP(r.Context(), userID, step, hashes); err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Отключение двухфакторной аутентификации. Требует пароль и код второго фактора
+func (a *app) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req models.TwoFactorDisableRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	var fieldErrors []problem.FieldError
+	if req.Password == "" {
+		fieldErrors = append(fieldErrors, problem.FieldError{Field: "password", Code: problem.FieldRequired, Message: "Password is required"})
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		fieldErrors = append(fieldErrors, problem.FieldError{Field: "code", Code: problem.FieldRequired, Message: "Code or recovery code is required"})
+	}
+	if len(fieldErrors) > 0 {
+		problem.WriteValidation(w, r, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	userID, err := contextUserID(r)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	user, err := a.storage.GetUserByID(r.Context(), userID)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	if !user.TOTPEnabled {
+		problem.Write(w, r, http.StatusConflict, problem.CodeTwoFactorNotEnabled, "Two-factor authentication is not enabled")
+		return
+	}
+
+	// Подбор пароля и кода ограничивается так же, как подбор при входе
+	loginKey, ipKey := loginAttemptKeys(r, user.Login, a.throttle.TrustProxyHeaders)
+	if !a.checkLoginLockout(w, r, loginKey, ipKey) {
+		return
+	}
+
+	valid := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)) == nil
+	if valid {
+		if valid, err = a.checkSecondFactor(r.Context(), user.ID, req.Code, req.RecoveryCode); err != nil {
+			writeInternalError(w, r, err)
+			return
+		}
+	}
+	if !valid {
+		if err = a.registerLoginFailure(r.Context(), loginKey, ipKey); err != nil {
+			writeInternalError(w, r, err)
+			return
+		}
+		problem.Write(w, r, http.StatusForbidden, problem.CodeInvalidCredentials, "Invalid password or two-factor code")
+		return
+	}
+
+	if err = a.storage.DisableTOTP(r.Context(), user.ID); err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Второй шаг входа: обмен токена первого шага и кода на токены сессии
+func (a *app) UserLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req models.TwoFactorLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	var fieldErrors []problem.FieldError
+	if req.ChallengeToken == "" {
+		fieldErrors = append(fieldErrors, problem.FieldError{Field: "challenge_token", Code: problem.FieldRequired, Message: "Challenge token is required"})
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		fieldErrors = append(fieldErrors, problem.FieldError{Field: "code", Code: problem.FieldRequired, Message: "Code or recovery code is required"})
+	}
+	if len(fieldErrors) > 0 {
+		problem.WriteValidation(w, r, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	subject, challengeID, err := auth.ParseChallengeToken(req.ChallengeToken)
+	if err != nil {
+		writeInvalidChallenge(w, r)
+		return
+	}
+	userID, err := strconv.Atoi(subject)
+	if err != nil {
+		writeInvalidChallenge(w, r)
+		return
+	}
+
+	// Погашенный после входа токен больше не принимается
+	err = a.storage.CheckTwoFactorChallenge(r.Context(), challengeID, userID)
+	if errors.Is(err, storage.ErrChallengeInvalid) {
+		writeInvalidChallenge(w, r)
+		return
+	}
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	// Удалённая учётная запись не отличается от недействительного токена
+	user, err := a.storage.GetUserByID(r.Context(), userID)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		writeInvalidChallenge(w, r)
+		return
+	}
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	loginKey, ipKey := loginAttemptKeys(r, user.Login, a.throttle.TrustProxyHeaders)
+	if !a.checkLoginLockout(w, r, loginKey, ipKey) {
+		return
+	}
+
+	valid, err := a.checkSecondFactor(r.Context(), user.ID, req.Code, req.RecoveryCode)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	if !valid {
+		if err = a.registerLoginFailure(r.Context(), loginKey, ipKey); err != nil {
+			writeInternalError(w, r, err)
+			return
+		}
+		problem.Write(w, r, http.StatusUnauthorized, problem.CodeInvalidTwoFactorCode, "Invalid two-factor code")
+		return
+	}
+
+	// Из параллельных запросов с одним токеном сессию получает только один
+	err = a.storage.ConsumeTwoFactorChallenge(r.Context(), challengeID, user.ID)
+	if errors.Is(err, storage.ErrChallengeInvalid) {
+		writeInvalidChallenge(w, r)
+		return
+	}
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	if err = a.storage.ResetLoginFailures(r.Context(), loginKey); err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	if err = a.startSession(r.Context(), w, user); err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+}
+
+// Ответ на первый шаг входа пользователя с двухфакторной аутентификацией
+func (a *app) writeTwoFactorChallenge(w http.ResponseWriter, r *http.Request, userID int) {
+	challengeToken, challengeID, err := auth.BuildChallengeToken(strconv.Itoa(userID))
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	if err = a.storage.CreateTwoFactorChallenge(r.Context(), challengeID, userID, auth.ChallengeTokenExp); err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(models.TwoFactorChallengeResponse{
+		TwoFactorRequired: true,
+		ChallengeToken:    challengeToken,
+		ExpiresIn:         int(auth.ChallengeTokenExp.Seconds()),
+	})
+}
+
+// Ответ на недействительный, погашенный или выданный удалённой учётной записи токен второго шага
+func writeInvalidChallenge(w http.ResponseWriter, r *http.Request) {
+	problem.Write(w, r, http.StatusUnauthorized, problem.CodeUnauthorized, "Invalid or expired challenge token")
+}
+
+// Проверка кода из приложения или кода восстановления. Принятый код повторно не принимается
+func (a *app) checkSecondFactor(ctx context.Context, userID int, code, recoveryCode string) (bool, error) {
+	if recoveryCode != "" {
+		err := a.storage.UseRecoveryCode(ctx, userID, auth.HashRecoveryCode(recoveryCode))
+		if errors.Is(err, storage.ErrRecoveryCodeInvalid) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	settings, err := a.storage.GetTOTP(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if !settings.Enabled {
+		return false, nil
+	}
+
+	secret, err := auth.OpenTOTPSecret(userID, settings.Secret)
+	if err != nil {
+		return false, err
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	err = a.storage.UseTOTPStep(ctx, userID, step)
+	if errors.Is(err, storage.ErrTOTPCodeReused) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/auth"
+	"github.com/dsemenov12/loyalty-gofermart/internal/helpers/totp"
+	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Секрет для проверки кодов в тестах. Хранится открытым текстом, как секреты,
+// сохранённые до появления шифрования
+const testTOTPSecret = "JBSWY3DPEHPK3PXP"
+
+// Ключ шифрования секретов TOTP в тестах
+const testTOTPKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+// Включение шифрования секретов TOTP на время теста
+func useTOTPKey(t *testing.T) {
+	require.NoError(t, auth.InitializeTOTPKey(testTOTPKey))
+	t.Cleanup(func() { auth.InitializeTOTPKey("") })
+}
+
+func currentTOTPCode(t *testing.T) string {
+	code, err := totp.Code(testTOTPSecret, totp.Step(time.Now()))
+	require.NoError(t, err)
+	return code
+}
+
+// Тестирование включения двухфакторной аутентификации
+func Test_app_SetupAndVerifyTwoFactor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	useTOTPKey(t)
+
+	m := mocks.NewMockStorage(ctrl)
+	m.EXPECT().GetUserByID(gomock.Any(), 1).Return(&models.User{ID: 1, Login: "user"}, nil).AnyTimes()
+
+	var secret string
+	m.EXPECT().SetTOTPSecret(gomock.Any(), 1, gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ int, s string) error {
+			secret = s
+			return nil
+		},
+	)
+
+	app := NewApp(m)
+
+	request := httptest.NewRequest(http.MethodPost, "/api/user/2fa/setup", nil)
+	request = request.WithContext(context.WithValue(request.Context(), auth.UserIDKey, "1"))
+	response := httptest.NewRecorder()
+	app.SetupTwoFactor(response, request)
+
+	require.Equal(t, http.StatusOK, response.Code)
+	var setup models.TwoFactorSetupResponse
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&setup))
+	assert.Contains(t, setup.URI, "otpauth://totp/")
+
+	// В хранилище попадает только зашифрованный секрет
+	assert.NotContains(t, secret, setup.Secret)
+	opened, err := auth.OpenTOTPSecret(1, secret)
+	require.NoError(t, err)
+	assert.Equal(t, setup.Secret, opened)
+
+	m.EXPECT().GetTOTP(gomock.Any(), 1).Return(&models.TOTP{Secret: secret}, nil).Times(2)
+	m.EXPECT().GetLoginLockout(gomock.Any(), "login:user", gomock.Any()).Times(2)
+	// Неверный код учитывается как неудачная попытка входа по логину и адресу
+	m.EXPECT().RecordLoginFailure(gomock.Any(), gomock.Any(), gomock.Any()).Return(1, nil).Times(2)
+
+	var storedHashes []string
+	m.EXPECT().EnableTOTP(gomock.Any(), 1, gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ int, _ int64, hashes []string) error {
+			storedHashes = hashes
+			return nil
+		},
+	)
+
+	code, err := totp.Code(setup.Secret, totp.Step(time.Now()))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{name: "wrong code", body: `{"code":"000000x"}`, wantCode: http.StatusBadRequest},
+		{name: "missing code", body: `{}`, wantCode: http.StatusBadRequest},
+		{name: "enabled", body: `{"code":"` + code + `"}`, wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/api/user/2fa/verify", strings.NewReader(tt.body))
+			request = request.WithContext(context.WithValue(request.Context(), auth.UserIDKey, "1"))
+			response := httptest.NewRecorder()
+
+			app.VerifyTwoFactor(response, request)
+
+			assert.Equal(t, tt.wantCode, response.Code)
+		})
+	}
+
+	// При блокировке код не проверяется
+	m.EXPECT().GetLoginLockout(gomock.Any(), "login:user", gomock.Any()).Return(time.Minute, nil)
+	request = httptest.NewRequest(http.MethodPost, "/api/user/2fa/verify", strings.NewReader(`{"code":"`+code+`"}`))
+	request = request.WithContext(context.WithValue(request.Context(), auth.UserIDKey, "1"))
+	response = httptest.NewRecorder()
+	app.VerifyTwoFactor(response, request)
+	assert.Equal(t, http.StatusTooManyRequests, response.Code)
+
+	// Коды восстановления показываются один раз, в хранилище попадают только их хеши
+	assert.Len(t, storedHashes, recoveryCodesCount)
+}
+
+// Хранилище токенов второго шага входа для тестов: выданные токены и погашенные
+type challengeStore struct {
+	issued   map[string]int
+	consumed map[string]bool
+}
+
+func expectChallenges(m *mocks.MockStorage) *challengeStore {
+	c := &challengeStore{issued: map[string]int{}, consumed: map[string]bool{}}
+	m.EXPECT().CreateTwoFactorChallenge(gomock.Any(), gomock.Any(), gomock.Any(), auth.ChallengeTokenExp).DoAndReturn(
+		func(_ context.Context, challengeID string, userID int, _ time.Duration) error {
+			c.issued[challengeID] = userID
+			return nil
+		},
+	).AnyTimes()
+	m.EXPECT().CheckTwoFactorChallenge(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, challengeID string, userID int) error {
+			if owner, ok := c.issued[challengeID]; !ok || owner != userID || c.consumed[challengeID] {
+				return storage.ErrChallengeInvalid
+			}
+			return nil
+		},
+	).AnyTimes()
+	m.EXPECT().ConsumeTwoFactorChallenge(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, challengeID string, userID int) error {
+			if owner, ok := c.issued[challengeID]; !ok || owner != userID || c.consumed[challengeID] {
+				return storage.ErrChallengeInvalid
+			}
+			c.consumed[challengeID] = true
+			return nil
+		},
+	).AnyTimes()
+	return c
+}
+
+// Тестирование входа с двухфакторной аутентификацией
+func Test_app_UserLoginTwoFactor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	user := &models.User{ID: 1, Login: "user", Password: testPasswordHash, TOTPEnabled: true}
+
+	m := mocks.NewMockStorage(ctrl)
+	m.EXPECT().GetUserByLogin(gomock.Any(), "user").Return(user, nil).AnyTimes()
+	m.EXPECT().GetUserByID(gomock.Any(), 1).Return(user, nil).AnyTimes()
+	m.EXPECT().GetUserByID(gomock.Any(), 2).Return(nil, storage.ErrUserNotFound).AnyTimes()
+	m.EXPECT().GetLoginLockout(gomock.Any(), "login:user", gomock.Any()).AnyTimes()
+	m.EXPECT().GetTOTP(gomock.Any(), 1).Return(&models.TOTP{Secret: testTOTPSecret, Enabled: true}, nil).AnyTimes()
+	challenges := expectChallenges(m)
+
+	app := NewApp(m)
+
+	// Первый шаг: пароль верный, но токены сессии не выдаются
+	login := func(t *testing.T) string {
+		request := httptest.NewRequest(http.MethodPost, "/api/user/login", strings.NewReader(`{"login":"user","password":"123456"}`))
+		response := httptest.NewRecorder()
+		app.UserLogin(response, request)
+
+		require.Equal(t, http.StatusAccepted, response.Code)
+		assert.Empty(t, response.Result().Cookies())
+		var challenge models.TwoFactorChallengeResponse
+		require.NoError(t, json.NewDecoder(response.Body).Decode(&challenge))
+		assert.True(t, challenge.TwoFactorRequired)
+		return challenge.ChallengeToken
+	}
+	challengeToken := login(t)
+
+	// Токен второго шага не принимается как access-токен
+	_, err := auth.GetUserID(challengeToken)
+	assert.ErrorIs(t, err, auth.ErrUnexpectedPurpose)
+
+	code := currentTOTPCode(t)
+	m.EXPECT().UseTOTPStep(gomock.Any(), 1, gomock.Any()).Return(storage.ErrTOTPCodeReused)
+	m.EXPECT().UseTOTPStep(gomock.Any(), 1, gomock.Any()).Return(nil)
+	m.EXPECT().UseRecoveryCode(gomock.Any(), 1, auth.HashRecoveryCode("abcde-fghij")).Return(nil)
+	m.EXPECT().UseRecoveryCode(gomock.Any(), 1, gomock.Any()).Return(storage.ErrRecoveryCodeInvalid)
+	m.EXPECT().RecordLoginFailure(gomock.Any(), gomock.Any(), gomock.Any()).Return(1, nil).Times(6)
+	m.EXPECT().ResetLoginFailures(gomock.Any(), "login:user").Times(2)
+	m.EXPECT().CreateSession(gomock.Any(), gomock.Any(), gomock.Any()).Times(2)
+
+	accessToken, _, err := auth.IssueToken("1", "session1", auth.RoleUser)
+	require.NoError(t, err)
+
+	// Токен удалённой учётной записи, выданный до её удаления
+	deletedToken, deletedID, err := auth.BuildChallengeToken("2")
+	require.NoError(t, err)
+	challenges.issued[deletedID] = 2
+
+	var secondToken string
+	tests := []struct {
+		name     string
+		token    func(t *testing.T) string
+		body     string
+		wantCode int
+	}{
+		{name: "reused totp code", token: func(*testing.T) string { return challengeToken }, body: `"code":"` + code + `"`, wantCode: http.StatusUnauthorized},
+		{name: "wrong totp code", token: func(*testing.T) string { return challengeToken }, body: `"code":"12345"`, wantCode: http.StatusUnauthorized},
+		{name: "totp code", token: func(*testing.T) string { return challengeToken }, body: `"code":"` + code + `"`, wantCode: http.StatusOK},
+		// Токен одноразовый: после входа код по нему не проверяется
+		{name: "used challenge", token: func(*testing.T) string { return challengeToken }, body: `"recovery_code":"abcde-fghij"`, wantCode: http.StatusUnauthorized},
+		{name: "recovery code", token: func(t *testing.T) string { secondToken = login(t); return secondToken }, body: `"recovery_code":"ABCDE-FGHIJ"`, wantCode: http.StatusOK},
+		{name: "used recovery code", token: func(t *testing.T) string { return login(t) }, body: `"recovery_code":"abcde-fghij"`, wantCode: http.StatusUnauthorized},
+		{name: "deleted account", token: func(*testing.T) string { return deletedToken }, body: `"code":"` + code + `"`, wantCode: http.StatusUnauthorized},
+		{name: "access token instead of challenge", token: func(*testing.T) string { return accessToken }, body: `"code":"` + code + `"`, wantCode: http.StatusUnauthorized},
+		{name: "missing code", token: func(*testing.T) string { return challengeToken }, body: `"code":""`, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"challenge_token":"` + tt.token(t) + `",` + tt.body + `}`
+			request := httptest.NewRequest(http.MethodPost, "/api/user/login/2fa", strings.NewReader(body))
+			response := httptest.NewRecorder()
+
+			app.UserLoginTwoFactor(response, request)
+
+			assert.Equal(t, tt.wantCode, response.Code)
+		})
+	}
+
+	// Погашены только токены, по которым выполнен вход
+	assert.Len(t, challenges.consumed, 2)
+	assert.True(t, challenges.consumed[challengeIDOf(t, secondToken)])
+}
+
+func challengeIDOf(t *testing.T, token string) string {
+	_, challengeID, err := auth.ParseChallengeToken(token)
+	require.NoError(t, err)
+	return challengeID
+}
+
+// Тестирование отключения двухфакторной аутентификации
+func Test_app_DisableTwoFactor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockStorage(ctrl)
+	m.EXPECT().GetUserByID(gomock.Any(), 1).Return(&models.User{ID: 1, Login: "user", Password: testPasswordHash, TOTPEnabled: true}, nil).AnyTimes()
+	m.EXPECT().GetLoginLockout(gomock.Any(), "login:user", gomock.Any()).AnyTimes()
+	m.EXPECT().GetTOTP(gomock.Any(), 1).Return(&models.TOTP{Secret: testTOTPSecret, Enabled: true}, nil).AnyTimes()
+	m.EXPECT().UseTOTPStep(gomock.Any(), 1, gomock.Any()).Return(nil)
+	m.EXPECT().RecordLoginFailure(gomock.Any(), gomock.Any(), gomock.Any()).Return(1, nil).Times(4)
+	m.EXPECT().DisableTOTP(gomock.Any(), 1).Return(nil)
+
+	app := NewApp(m)
+	code := currentTOTPCode(t)
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{name: "wrong password", body: `{"password":"654321","code":"` + code + `"}`, wantCode: http.StatusForbidden},
+		{name: "wrong code", body: `{"password":"123456","code":"abcdef"}`, wantCode: http.StatusForbidden},
+		{name: "missing code", body: `{"password":"123456"}`, wantCode: http.StatusBadRequest},
+		{name: "disabled", body: `{"password":"123456","code":"` + code + `"}`, wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/api/user/2fa/disable", strings.NewReader(tt.body))
+			request = request.WithContext(context.WithValue(request.Context(), auth.UserIDKey, "1"))
+			response := httptest.NewRecorder()
+
+			app.DisableTwoFactor(response, request)
+
+			assert.Equal(t, tt.wantCode, response.Code)
+		})
+	}
+}
+
+// Тестирование настройки без ключа шифрования секретов
+func Test_app_SetupTwoFactor_NoKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	app := NewApp(mocks.NewMockStorage(ctrl))
+
+	request := httptest.NewRequest(http.MethodPost, "/api/user/2fa/setup", nil)
+	request = request.WithContext(context.WithValue(request.Context(), auth.UserIDKey, "1"))
+	response := httptest.NewRecorder()
+	app.SetupTwoFactor(response, request)
+
+	assert.Equal(t, http.StatusServiceUnavailable, response.Code)
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"unicode/utf8"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/helpers/luhn"
+	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/problem"
+)
+
+// Проверка обязательных полей запросов регистрации и входа
+func validateCredentials(req models.UserRegister) []problem.FieldError {
+	var fieldErrors []problem.FieldError
+	if req.Login == "" {
+		fieldErrors = append(fieldErrors, problem.FieldError{Field: "login", Code: problem.FieldRequired, Message: "Login is required"})
+	}
+	if req.Password == "" {
+		fieldErrors = append(fieldErrors, problem.FieldError{Field: "password", Code: problem.FieldRequired, Message: "Password is required"})
+	}
+	return fieldErrors
+}
+
+// Проверка полей запроса на списание
+func validateWithdrawal(req models.WithdrawRequest) []problem.FieldError {
+	var fieldErrors []problem.FieldError
+	switch {
+	case req.Order == "":
+		fieldErrors = append(fieldErrors, problem.FieldError{Field: "order", Code: problem.FieldRequired, Message: "Order number is required"})
+	case !luhn.ValidateLuhn(req.Order):
+		fieldErrors = append(fieldErrors, problem.FieldError{Field: "order", Code: problem.FieldInvalid, Message: "Invalid order number"})
+	}
+	if !req.Sum.IsPositive() {
+		fieldErrors = append(fieldErrors, problem.FieldError{Field: "sum", Code: problem.FieldInvalid, Message: "Sum must be positive"})
+	}
+	return fieldErrors
+}
+
+// Максимальная длина причины корректировки, совпадает с размером колонки
+const maxAdjustmentReasonLength = 255
+
+// Проверка полей запроса на корректировку баланса
+func validateBalanceAdjustment(req models.BalanceAdjustmentRequest) []problem.FieldError {
+	var fieldErrors []problem.FieldError
+	if req.Amount.IsZero() {
+		fieldErrors = append(fieldErrors, problem.FieldError{Field: "amount", Code: problem.FieldInvalid, Message: "Amount must not be zero"})
+	}
+	switch {
+	case req.Reason == "":
+		fieldErrors = append(fieldErrors, problem.FieldError{Field: "reason", Code: problem.FieldRequired, Message: "Reason is required"})
+	case utf8.RuneCountInString(req.Reason) > maxAdjustmentReasonLength:
+		fieldErrors = append(fieldErrors, problem.FieldError{Field: "reason", Code: problem.FieldTooLong, Message: "Reason is too long"})
+	}
+	return fieldErrors
+}
@@ -0,0 +1,145 @@
+// Пакет health отвечает на проверки живости и готовности сервиса.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Статусы проверок
+const (
+	StatusOK          = "ok"
+	StatusFail        = "fail"
+	StatusDegraded    = "degraded"    // не прошла необязательная проверка, трафик принимается
+	StatusUnavailable = "unavailable" // не прошла обязательная проверка
+)
+
+// Причины сбоя проверки в ответе
+const (
+	ErrorCheckFailed = "check failed"
+	ErrorTimeout     = "timeout"
+)
+
+// Проверка зависимости. Ошибка означает, что зависимость недоступна
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name     string
+	critical bool
+	fn       CheckFunc
+}
+
+// Результат проверки одной зависимости
+type CheckResult struct {
+	Status     string `json:"status"`
+	Critical   bool   `json:"critical"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"` // без подробностей: эндпоинт доступен без авторизации
+}
+
+// Ответ эндпоинта готовности
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Набор проверок зависимостей. Каждая проверка ограничена тайм-аутом
+type Checker struct {
+	timeout time.Duration
+	checks  []check
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Добавляет проверку. Сбой обязательной проверки снимает сервис с балансировки,
+// сбой необязательной только отражается в ответе
+func (c *Checker) Add(name string, critical bool, fn CheckFunc) {
+	c.checks = append(c.checks, check{name: name, critical: critical, fn: fn})
+}
+
+// Выполняет все проверки параллельно
+func (c *Checker) Check(ctx context.Context) Report {
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(c.checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, ch := range c.checks {
+		wg.Add(1)
+		go func(ch check) {
+			defer wg.Done()
+
+			result := c.run(ctx, ch)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[ch.name] = result
+			if result.Status == StatusOK {
+				return
+			}
+			if ch.critical {
+				report.Status = StatusUnavailable
+			} else if report.Status == StatusOK {
+				report.Status = StatusDegraded
+			}
+		}(ch)
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, ch check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	started := time.Now()
+	err := ch.fn(ctx)
+	result := CheckResult{
+		Status:     StatusOK,
+		Critical:   ch.critical,
+		DurationMs: time.Since(started).Milliseconds(),
+	}
+	if err != nil {
+		// Текст ошибки может содержать адреса и имена внутренних сервисов, поэтому пишется только в лог
+		logger.Log.Warn("readiness check failed", zap.String("check", ch.name), zap.Error(err))
+		result.Status = StatusFail
+		result.Error = ErrorCheckFailed
+		if errors.Is(err, context.DeadlineExceeded) {
+			result.Error = ErrorTimeout
+		}
+	}
+	return result
+}
+
+// Эндпоинт готовности: 503, если не прошла хотя бы одна обязательная проверка
+func (c *Checker) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := c.Check(r.Context())
+
+	status := http.StatusOK
+	if report.Status == StatusUnavailable {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+// Эндпоинт живости: процесс запущен и обрабатывает запросы. Зависимости не проверяются,
+// чтобы сбой БД не приводил к перезапуску сервиса
+func Liveness(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": StatusOK})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
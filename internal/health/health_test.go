@@ -0,0 +1,103 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ok(ctx context.Context) error { return nil }
+
+func failing(ctx context.Context) error { return errors.New("connection refused") }
+
+// Проверка, не укладывающаяся в тайм-аут
+func hanging(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// Тестирование эндпоинта готовности
+func TestChecker_Readiness(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(c *Checker)
+		wantCode   int
+		wantStatus string
+		wantChecks map[string]string
+		wantErrors map[string]string
+	}{
+		{
+			name: "all ok",
+			setup: func(c *Checker) {
+				c.Add("postgres", true, ok)
+				c.Add("accrual", false, ok)
+			},
+			wantCode:   http.StatusOK,
+			wantStatus: StatusOK,
+			wantChecks: map[string]string{"postgres": StatusOK, "accrual": StatusOK},
+		},
+		{
+			name: "optional dependency down",
+			setup: func(c *Checker) {
+				c.Add("postgres", true, ok)
+				c.Add("accrual", false, failing)
+			},
+			wantCode:   http.StatusOK,
+			wantStatus: StatusDegraded,
+			wantChecks: map[string]string{"postgres": StatusOK, "accrual": StatusFail},
+			wantErrors: map[string]string{"postgres": "", "accrual": ErrorCheckFailed},
+		},
+		{
+			name: "critical dependency timeout",
+			setup: func(c *Checker) {
+				c.Add("postgres", true, hanging)
+				c.Add("accrual", false, failing)
+			},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: StatusUnavailable,
+			wantChecks: map[string]string{"postgres": StatusFail, "accrual": StatusFail},
+			wantErrors: map[string]string{"postgres": ErrorTimeout, "accrual": ErrorCheckFailed},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewChecker(50 * time.Millisecond)
+			tt.setup(checker)
+
+			response := httptest.NewRecorder()
+			checker.Readiness(response, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			assert.Equal(t, tt.wantCode, response.Code)
+
+			// Текст ошибки зависимости наружу не отдаётся
+			assert.NotContains(t, response.Body.String(), "connection refused")
+
+			var report Report
+			require.NoError(t, json.NewDecoder(response.Body).Decode(&report))
+			assert.Equal(t, tt.wantStatus, report.Status)
+			for name, status := range tt.wantChecks {
+				assert.Equal(t, status, report.Checks[name].Status, name)
+			}
+			for name, message := range tt.wantErrors {
+				assert.Equal(t, message, report.Checks[name].Error, name)
+			}
+		})
+	}
+}
+
+// Тестирование эндпоинта живости
+func TestLiveness(t *testing.T) {
+	response := httptest.NewRecorder()
+	Liveness(response, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, response.Body.String())
+}
@@ -0,0 +1,31 @@
+package backoff
+
+import "time"
+
+// Экспоненциальная задержка после серии неудачных попыток
+type Policy struct {
+	Threshold int           // число попыток без задержки
+	Base      time.Duration // задержка после достижения порога
+	Max       time.Duration // верхняя граница задержки
+}
+
+// Задержка после failures неудачных попыток: до порога задержки нет,
+// затем она удваивается с каждой следующей попыткой
+func (p Policy) Delay(failures int) time.Duration {
+	if p.Threshold <= 0 || failures < p.Threshold || p.Base <= 0 {
+		return 0
+	}
+
+	delay := p.Base
+	for i := p.Threshold; i < failures; i++ {
+		if p.Max > 0 && delay >= p.Max/2 {
+			return p.Max
+		}
+		delay *= 2
+	}
+
+	if p.Max > 0 && delay > p.Max {
+		return p.Max
+	}
+	return delay
+}
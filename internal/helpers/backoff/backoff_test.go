@@ -0,0 +1,34 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+// Тестируем функцию Delay
+func TestPolicy_Delay(t *testing.T) {
+	policy := Policy{Threshold: 5, Base: time.Minute, Max: time.Hour}
+
+	tests := []struct {
+		name     string
+		failures int
+		expected time.Duration
+	}{
+		{"No failures", 0, 0},
+		{"Below threshold", 4, 0},
+		{"Threshold reached", 5, time.Minute},
+		{"Doubles after threshold", 6, 2 * time.Minute},
+		{"Keeps doubling", 8, 8 * time.Minute},
+		{"Capped by max", 12, time.Hour},
+		{"Large number of failures", 1000, time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := policy.Delay(tt.failures)
+			if result != tt.expected {
+				t.Errorf("For %d failures, expected %v, but got %v", tt.failures, tt.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Секрет из тестовых векторов RFC 6238
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+// Тестируем функцию Code на векторах RFC 6238 (младшие шесть цифр)
+func TestCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		unix     int64
+		expected string
+	}{
+		{"1970-01-01 00:00:59", 59, "287082"},
+		{"2005-03-18 01:58:29", 1111111109, "081804"},
+		{"2005-03-18 01:58:31", 1111111111, "050471"},
+		{"2009-02-13 23:31:30", 1234567890, "005924"},
+		{"2033-05-18 03:33:20", 2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != tt.expected {
+				t.Errorf("At %d, expected %s, but got %s", tt.unix, tt.expected, result)
+			}
+		})
+	}
+}
+
+// Тестируем функцию Validate
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current, _ := Code(rfcSecret, Step(now))
+	previous, _ := Code(rfcSecret, Step(now)-1)
+	stale, _ := Code(rfcSecret, Step(now)-2)
+
+	tests := []struct {
+		name     string
+		code     string
+		expected bool
+		step     int64
+	}{
+		{"Current code", current, true, Step(now)},
+		{"Code with spaces", current[:3] + " " + current[3:], true, Step(now)},
+		{"Previous step within skew", previous, true, Step(now) - 1},
+		{"Stale code", stale, false, 0},
+		{"Wrong length", "12345", false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(rfcSecret, tt.code, now)
+			if ok != tt.expected || step != tt.step {
+				t.Errorf("For code %s, expected (%d, %v), but got (%d, %v)", tt.code, tt.step, tt.expected, step, ok)
+			}
+		})
+	}
+}
+
+// Тестируем функции GenerateSecret и URI
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("Expected 32 base32 characters, but got %d", len(secret))
+	}
+	if _, err = Code(secret, 1); err != nil {
+		t.Errorf("Generated secret is not valid: %v", err)
+	}
+
+	uri := URI("Gophermart", "user@example.com", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/Gophermart:user@example.com?") || !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("Unexpected uri %s", uri)
+	}
+}
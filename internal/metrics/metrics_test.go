@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/money"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// Тестирование замера времени выполнения методов хранилища
+func TestStorage_observe(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mocks.NewMockStorage(ctrl)
+	balance := &models.Balance{Current: money.MustParse("500")}
+	mockStorage.EXPECT().GetBalanceByUserID(gomock.Any(), 1).Return(balance, nil)
+	mockStorage.EXPECT().GetBalanceByUserID(gomock.Any(), 2).Return(nil, errors.New("connection refused"))
+
+	s := NewStorage(mockStorage)
+	okBefore := testutil.CollectAndCount(StorageQueryDuration)
+
+	result, err := s.GetBalanceByUserID(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, balance, result)
+
+	_, err = s.GetBalanceByUserID(context.Background(), 2)
+	assert.EqualError(t, err, "connection refused")
+
+	// По серии на каждый исход вызова метода
+	assert.Equal(t, okBefore+2, testutil.CollectAndCount(StorageQueryDuration))
+}
+
+// Тестирование эндпоинта /metrics
+func TestHandler(t *testing.T) {
+	PointsAccrued.Add(100)
+
+	response := httptest.NewRecorder()
+	Handler().ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, response.Code)
+	body, _ := io.ReadAll(response.Body)
+	assert.Contains(t, string(body), "gophermart_points_accrued_total 100")
+	assert.Contains(t, string(body), "go_goroutines")
+}
+
+// Тестирование учёта начисленных и списанных баллов: повторы и ошибки не учитываются
+func TestStorage_points(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mocks.NewMockStorage(ctrl)
+	gomock.InOrder(
+		mockStorage.EXPECT().SettleOrder(gomock.Any(), "12345678903", "PROCESSED", money.MustParse("50")).Return(true, nil),
+		mockStorage.EXPECT().SettleOrder(gomock.Any(), "12345678903", "PROCESSED", money.MustParse("50")).Return(false, nil),
+	)
+	mockStorage.EXPECT().SettleOrder(gomock.Any(), "2377225624", "PROCESSED", money.MustParse("70")).Return(false, errors.New("connection refused"))
+	gomock.InOrder(
+		mockStorage.EXPECT().WithdrawUserBalance(gomock.Any(), "79927398713", money.MustParse("20"), "key-1").Return(true, nil),
+		mockStorage.EXPECT().WithdrawUserBalance(gomock.Any(), "79927398713", money.MustParse("20"), "key-1").Return(false, nil),
+	)
+
+	s := NewStorage(mockStorage)
+	accruedBefore := testutil.ToFloat64(PointsAccrued)
+	withdrawnBefore := testutil.ToFloat64(PointsWithdrawn)
+
+	for i := 0; i < 2; i++ {
+		_, err := s.SettleOrder(context.Background(), "12345678903", "PROCESSED", money.MustParse("50"))
+		assert.NoError(t, err)
+		_, err = s.WithdrawUserBalance(context.Background(), "79927398713", money.MustParse("20"), "key-1")
+		assert.NoError(t, err)
+	}
+	_, err := s.SettleOrder(context.Background(), "2377225624", "PROCESSED", money.MustParse("70"))
+	assert.Error(t, err)
+
+	assert.Equal(t, accruedBefore+50, testutil.ToFloat64(PointsAccrued))
+	assert.Equal(t, withdrawnBefore+20, testutil.ToFloat64(PointsWithdrawn))
+}
@@ -0,0 +1,239 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/money"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage"
+)
+
+// Хранилище, замеряющее время выполнения каждого метода
+type Storage struct {
+	next storage.Storage
+}
+
+var _ storage.Storage = (*Storage)(nil)
+
+func NewStorage(next storage.Storage) *Storage {
+	return &Storage{next: next}
+}
+
+func observe(method string, started time.Time, err *error) {
+	outcome := OutcomeOK
+	if *err != nil {
+		outcome = OutcomeError
+	}
+	StorageQueryDuration.WithLabelValues(method, outcome).Observe(time.Since(started).Seconds())
+}
+
+func (s *Storage) CreateUser(ctx context.Context, login, hashedPassword string) (err error) {
+	defer observe("CreateUser", time.Now(), &err)
+	return s.next.CreateUser(ctx, login, hashedPassword)
+}
+
+func (s *Storage) GetUserByLogin(ctx context.Context, login string) (_ *models.User, err error) {
+	defer observe("GetUserByLogin", time.Now(), &err)
+	return s.next.GetUserByLogin(ctx, login)
+}
+
+func (s *Storage) GetUserByID(ctx context.Context, userID int) (_ *models.User, err error) {
+	defer observe("GetUserByID", time.Now(), &err)
+	return s.next.GetUserByID(ctx, userID)
+}
+
+func (s *Storage) DeleteUser(ctx context.Context, userID int, balancePolicy string) (err error) {
+	defer observe("DeleteUser", time.Now(), &err)
+	return s.next.DeleteUser(ctx, userID, balancePolicy)
+}
+
+func (s *Storage) UpdatePassword(ctx context.Context, userID int, hashedPassword string) (err error) {
+	defer observe("UpdatePassword", time.Now(), &err)
+	return s.next.UpdatePassword(ctx, userID, hashedPassword)
+}
+
+func (s *Storage) CreatePasswordResetToken(ctx context.Context, userID int, tokenHash string, ttl time.Duration) (err error) {
+	defer observe("CreatePasswordResetToken", time.Now(), &err)
+	return s.next.CreatePasswordResetToken(ctx, userID, tokenHash, ttl)
+}
+
+func (s *Storage) GetPasswordResetUser(ctx context.Context, tokenHash string) (_ *models.User, err error) {
+	defer observe("GetPasswordResetUser", time.Now(), &err)
+	return s.next.GetPasswordResetUser(ctx, tokenHash)
+}
+
+func (s *Storage) ResetPassword(ctx context.Context, tokenHash, hashedPassword string) (err error) {
+	defer observe("ResetPassword", time.Now(), &err)
+	return s.next.ResetPassword(ctx, tokenHash, hashedPassword)
+}
+
+func (s *Storage) SaveOrder(ctx context.Context, orderNumber string) (_ bool, err error) {
+	defer observe("SaveOrder", time.Now(), &err)
+	return s.next.SaveOrder(ctx, orderNumber)
+}
+
+func (s *Storage) GetOrdersByUser(ctx context.Context) (_ []models.Order, err error) {
+	defer observe("GetOrdersByUser", time.Now(), &err)
+	return s.next.GetOrdersByUser(ctx)
+}
+
+func (s *Storage) GetOrdersByUserID(ctx context.Context, userID int) (_ []models.Order, err error) {
+	defer observe("GetOrdersByUserID", time.Now(), &err)
+	return s.next.GetOrdersByUserID(ctx, userID)
+}
+
+func (s *Storage) GetOrderByNumber(ctx context.Context, orderNumber string) (_ *models.Order, err error) {
+	defer observe("GetOrderByNumber", time.Now(), &err)
+	return s.next.GetOrderByNumber(ctx, orderNumber)
+}
+
+func (s *Storage) GetPendingOrders(ctx context.Context, limit int) (_ []models.Order, err error) {
+	defer observe("GetPendingOrders", time.Now(), &err)
+	return s.next.GetPendingOrders(ctx, limit)
+}
+
+func (s *Storage) ScheduleOrderPoll(ctx context.Context, orderNumber string, delay time.Duration) (err error) {
+	defer observe("ScheduleOrderPoll", time.Now(), &err)
+	return s.next.ScheduleOrderPoll(ctx, orderNumber, delay)
+}
+
+func (s *Storage) GetBalance(ctx context.Context) (_ *models.Balance, err error) {
+	defer observe("GetBalance", time.Now(), &err)
+	return s.next.GetBalance(ctx)
+}
+
+func (s *Storage) GetBalanceByUserID(ctx context.Context, userID int) (_ *models.Balance, err error) {
+	defer observe("GetBalanceByUserID", time.Now(), &err)
+	return s.next.GetBalanceByUserID(ctx, userID)
+}
+
+func (s *Storage) AdjustBalance(ctx context.Context, adjustment models.BalanceAdjustment) (_ *models.BalanceAdjustment, err error) {
+	defer observe("AdjustBalance", time.Now(), &err)
+	return s.next.AdjustBalance(ctx, adjustment)
+}
+
+func (s *Storage) GetBalanceAdjustments(ctx context.Context, userID int) (_ []models.BalanceAdjustment, err error) {
+	defer observe("GetBalanceAdjustments", time.Now(), &err)
+	return s.next.GetBalanceAdjustments(ctx, userID)
+}
+
+func (s *Storage) GetBalanceHistory(ctx context.Context) (_ []models.Posting, err error) {
+	defer observe("GetBalanceHistory", time.Now(), &err)
+	return s.next.GetBalanceHistory(ctx)
+}
+
+// Списанные баллы учитываются только при фактическом списании, повтор по ключу идемпотентности не считается
+func (s *Storage) WithdrawUserBalance(ctx context.Context, orderNumber string, amount money.Amount, idempotencyKey string) (withdrawn bool, err error) {
+	defer observe("WithdrawUserBalance", time.Now(), &err)
+	withdrawn, err = s.next.WithdrawUserBalance(ctx, orderNumber, amount, idempotencyKey)
+	if err == nil && withdrawn {
+		PointsWithdrawn.Add(amount.Float64())
+	}
+	return withdrawn, err
+}
+
+func (s *Storage) GetUserWithdrawals(ctx context.Context) (_ []models.Withdrawal, err error) {
+	defer observe("GetUserWithdrawals", time.Now(), &err)
+	return s.next.GetUserWithdrawals(ctx)
+}
+
+func (s *Storage) UpdateOrderStatus(ctx context.Context, orderNumber, status string, accrual money.Amount) (err error) {
+	defer observe("UpdateOrderStatus", time.Now(), &err)
+	return s.next.UpdateOrderStatus(ctx, orderNumber, status, accrual)
+}
+
+// Начисленные баллы учитываются только при фактическом начислении, повторная обработка заказа не считается
+func (s *Storage) SettleOrder(ctx context.Context, orderNumber, status string, accrual money.Amount) (credited bool, err error) {
+	defer observe("SettleOrder", time.Now(), &err)
+	credited, err = s.next.SettleOrder(ctx, orderNumber, status, accrual)
+	if err == nil && credited {
+		PointsAccrued.Add(accrual.Float64())
+	}
+	return credited, err
+}
+
+func (s *Storage) CreateSession(ctx context.Context, session models.Session, refreshTokenHash string) (err error) {
+	defer observe("CreateSession", time.Now(), &err)
+	return s.next.CreateSession(ctx, session, refreshTokenHash)
+}
+
+func (s *Storage) RotateRefreshToken(ctx context.Context, refreshTokenHash, newRefreshTokenHash string, expiresAt time.Time) (_ *models.Session, err error) {
+	defer observe("RotateRefreshToken", time.Now(), &err)
+	return s.next.RotateRefreshToken(ctx, refreshTokenHash, newRefreshTokenHash, expiresAt)
+}
+
+func (s *Storage) RevokeSession(ctx context.Context, sessionID string) (err error) {
+	defer observe("RevokeSession", time.Now(), &err)
+	return s.next.RevokeSession(ctx, sessionID)
+}
+
+func (s *Storage) IsSessionRevoked(ctx context.Context, sessionID string) (_ bool, err error) {
+	defer observe("IsSessionRevoked", time.Now(), &err)
+	return s.next.IsSessionRevoked(ctx, sessionID)
+}
+
+func (s *Storage) GetLoginLockout(ctx context.Context, keys ...string) (_ time.Duration, err error) {
+	defer observe("GetLoginLockout", time.Now(), &err)
+	return s.next.GetLoginLockout(ctx, keys...)
+}
+
+func (s *Storage) RecordLoginFailure(ctx context.Context, key string, window time.Duration) (_ int, err error) {
+	defer observe("RecordLoginFailure", time.Now(), &err)
+	return s.next.RecordLoginFailure(ctx, key, window)
+}
+
+func (s *Storage) LockLogin(ctx context.Context, key string, d time.Duration) (err error) {
+	defer observe("LockLogin", time.Now(), &err)
+	return s.next.LockLogin(ctx, key, d)
+}
+
+func (s *Storage) ResetLoginFailures(ctx context.Context, key string) (err error) {
+	defer observe("ResetLoginFailures", time.Now(), &err)
+	return s.next.ResetLoginFailures(ctx, key)
+}
+
+func (s *Storage) GetTOTP(ctx context.Context, userID int) (_ *models.TOTP, err error) {
+	defer observe("GetTOTP", time.Now(), &err)
+	return s.next.GetTOTP(ctx, userID)
+}
+
+func (s *Storage) SetTOTPSecret(ctx context.Context, userID int, secret string) (err error) {
+	defer observe("SetTOTPSecret", time.Now(), &err)
+	return s.next.SetTOTPSecret(ctx, userID, secret)
+}
+
+func (s *Storage) EnableTOTP(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) (err error) {
+	defer observe("EnableTOTP", time.Now(), &err)
+	return s.next.EnableTOTP(ctx, userID, step, recoveryCodeHashes)
+}
+
+func (s *Storage) DisableTOTP(ctx context.Context, userID int) (err error) {
+	defer observe("DisableTOTP", time.Now(), &err)
+	return s.next.DisableTOTP(ctx, userID)
+}
+
+func (s *Storage) UseTOTPStep(ctx context.Context, userID int, step int64) (err error) {
+	defer observe("UseTOTPStep", time.Now(), &err)
+	return s.next.UseTOTPStep(ctx, userID, step)
+}
+
+func (s *Storage) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (err error) {
+	defer observe("UseRecoveryCode", time.Now(), &err)
+	return s.next.UseRecoveryCode(ctx, userID, codeHash)
+}
+
+func (s *Storage) CreateTwoFactorChallenge(ctx context.Context, challengeID string, userID int, ttl time.Duration) (err error) {
+	defer observe("CreateTwoFactorChallenge", time.Now(), &err)
+	return s.next.CreateTwoFactorChallenge(ctx, challengeID, userID, ttl)
+}
+
+func (s *Storage) CheckTwoFactorChallenge(ctx context.Context, challengeID string, userID int) (err error) {
+	defer observe("CheckTwoFactorChallenge", time.Now(), &err)
+	return s.next.CheckTwoFactorChallenge(ctx, challengeID, userID)
+}
+
+func (s *Storage) ConsumeTwoFactorChallenge(ctx context.Context, challengeID string, userID int) (err error) {
+	defer observe("ConsumeTwoFactorChallenge", time.Now(), &err)
+	return s.next.ConsumeTwoFactorChallenge(ctx, challengeID, userID)
+}
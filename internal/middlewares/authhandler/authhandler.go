@@ -3,27 +3,95 @@ package authhandler
 import (
 	"context"
 	"net/http"
+	"strings"
 
 	"github.com/dsemenov12/loyalty-gofermart/internal/auth"
+	"github.com/dsemenov12/loyalty-gofermart/internal/cookies"
+	"github.com/dsemenov12/loyalty-gofermart/internal/logger"
+	"github.com/dsemenov12/loyalty-gofermart/internal/problem"
+	"go.uber.org/zap"
 )
 
+// Проверка отзыва сессии, к которой относится токен
+type SessionChecker interface {
+	IsSessionRevoked(ctx context.Context, sessionID string) (bool, error)
+}
+
+// Пока проверка не настроена, токены не сверяются со списком отозванных сессий
+var sessions SessionChecker
+
+func Initialize(checker SessionChecker) {
+	sessions = checker
+}
+
 func AuthHandle(handlerFunc http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var userID string
-		jwtToken, _ := r.Cookie("Authorization")
-		if jwtToken == nil {
-			w.WriteHeader(http.StatusUnauthorized)
+		tokenString, ok := tokenFromRequest(r)
+		if !ok {
+			problem.Write(w, r, http.StatusUnauthorized, problem.CodeUnauthorized, "Authorization required")
 			return
-		} 
+		}
 
-		userID, err := auth.GetUserID(jwtToken.Value)
-		if err != nil || userID == "" {
-			w.WriteHeader(http.StatusUnauthorized)
+		claims, err := auth.ParseToken(tokenString)
+		if err != nil || claims.UserID == "" {
+			problem.Write(w, r, http.StatusUnauthorized, problem.CodeUnauthorized, "Invalid or expired token")
 			return
 		}
 
-		r = r.WithContext(context.WithValue(context.Background(), auth.UserIDKey, userID))
+		// Токен без сессии нельзя отозвать, поэтому он не принимается
+		if sessions != nil {
+			if claims.SessionID == "" {
+				problem.Write(w, r, http.StatusUnauthorized, problem.CodeUnauthorized, "Invalid or expired token")
+				return
+			}
+			revoked, err := sessions.IsSessionRevoked(r.Context(), claims.SessionID)
+			if err != nil {
+				logger.Log.Error("session check failed", zap.Error(err))
+				problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "Internal server error")
+				return
+			}
+			if revoked {
+				problem.Write(w, r, http.StatusUnauthorized, problem.CodeSessionRevoked, "Session has been revoked")
+				return
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), auth.UserIDKey, claims.UserID)
+		ctx = context.WithValue(ctx, auth.SessionIDKey, claims.SessionID)
+		ctx = context.WithValue(ctx, auth.RoleKey, claims.Role)
+		r = r.WithContext(ctx)
+
+		handlerFunc(w, r)
+	})
+}
+
+// Пропускает только пользователей с указанной ролью. Используется после AuthHandle
+func RoleHandle(role string, handlerFunc http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if userRole, _ := r.Context().Value(auth.RoleKey).(string); userRole != role {
+			problem.Write(w, r, http.StatusForbidden, problem.CodeForbidden, "Insufficient permissions")
+			return
+		}
 
 		handlerFunc(w, r)
 	})
-}
\ No newline at end of file
+}
+
+// Токен из заголовка Authorization: Bearer, а при его отсутствии из куки.
+// Заголовок с другой схемой авторизации не заменяется куки
+func tokenFromRequest(r *http.Request) (string, bool) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, found := strings.Cut(header, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") {
+			return "", false
+		}
+		token = strings.TrimSpace(token)
+		return token, token != ""
+	}
+
+	cookie, _ := r.Cookie(cookies.AccessToken)
+	if cookie == nil || cookie.Value == "" {
+		return "", false
+	}
+	return cookie.Value, true
+}
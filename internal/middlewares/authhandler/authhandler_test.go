@@ -0,0 +1,113 @@
+package authhandler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+// Список отозванных сессий
+type revokedSessions map[string]bool
+
+func (s revokedSessions) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	return s[sessionID], nil
+}
+
+// Тестирование проверки отзыва сессии
+func TestAuthHandle(t *testing.T) {
+	Initialize(revokedSessions{"revoked": true})
+	defer Initialize(nil)
+
+	token := func(sessionID string) string {
+		tokenString, _, err := auth.IssueToken("1", sessionID, auth.RoleUser)
+		assert.NoError(t, err)
+		return tokenString
+	}
+
+	tests := []struct {
+		name     string
+		cookie   string
+		header   string
+		wantCode int
+	}{
+		{name: "active session", cookie: token("active"), wantCode: http.StatusOK},
+		{name: "revoked session", cookie: token("revoked"), wantCode: http.StatusUnauthorized},
+		{name: "token without session", cookie: token(""), wantCode: http.StatusUnauthorized},
+		{name: "invalid token", cookie: "invalid", wantCode: http.StatusUnauthorized},
+		{name: "no cookie", wantCode: http.StatusUnauthorized},
+		{name: "bearer header", header: "Bearer " + token("active"), wantCode: http.StatusOK},
+		{name: "bearer lowercase scheme", header: "bearer " + token("active"), wantCode: http.StatusOK},
+		{name: "bearer revoked session", header: "Bearer " + token("revoked"), wantCode: http.StatusUnauthorized},
+		{name: "header takes precedence", header: "Bearer invalid", cookie: token("active"), wantCode: http.StatusUnauthorized},
+		{name: "unsupported scheme", header: "Basic dXNlcjpwYXNz", wantCode: http.StatusUnauthorized},
+		{name: "empty bearer", header: "Bearer ", wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := AuthHandle(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "1", r.Context().Value(auth.UserIDKey))
+				assert.Equal(t, "active", r.Context().Value(auth.SessionIDKey))
+				w.WriteHeader(http.StatusOK)
+			})
+
+			request := httptest.NewRequest(http.MethodGet, "/api/user/orders", nil)
+			if tt.cookie != "" {
+				request.AddCookie(&http.Cookie{Name: "Authorization", Value: tt.cookie})
+			}
+			if tt.header != "" {
+				request.Header.Set("Authorization", tt.header)
+			}
+			response := httptest.NewRecorder()
+
+			handler(response, request)
+
+			res := response.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.wantCode, res.StatusCode)
+		})
+	}
+}
+
+// Тестирование проверки роли из токена
+func TestRoleHandle(t *testing.T) {
+	token := func(role string) string {
+		tokenString, _, err := auth.IssueToken("1", "session1", role)
+		assert.NoError(t, err)
+		return tokenString
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		wantCode int
+	}{
+		{name: "admin", token: token(auth.RoleAdmin), wantCode: http.StatusOK},
+		{name: "user", token: token(auth.RoleUser), wantCode: http.StatusForbidden},
+		{name: "token without role", token: token(""), wantCode: http.StatusForbidden},
+		{name: "no token", wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := AuthHandle(RoleHandle(auth.RoleAdmin, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			request := httptest.NewRequest(http.MethodGet, "/api/admin/users/1", nil)
+			if tt.token != "" {
+				request.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			response := httptest.NewRecorder()
+
+			handler(response, request)
+
+			assert.Equal(t, tt.wantCode, response.Code)
+		})
+	}
+}
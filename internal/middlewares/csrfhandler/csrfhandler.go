@@ -0,0 +1,51 @@
+package csrfhandler
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/cookies"
+	"github.com/dsemenov12/loyalty-gofermart/internal/problem"
+)
+
+// Проверка CSRF-токена по схеме double-submit: значение заголовка X-CSRF-Token
+// должно совпадать с куки csrf_token. Проверяются только изменяющие запросы
+// с авторизацией по куки: заголовок Authorization браузер сам не подставляет
+func CSRFHandle(handlerFunc http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !cookies.CSRFEnabled() || isSafeMethod(r.Method) || !usesCookieAuth(r) {
+			handlerFunc(w, r)
+			return
+		}
+
+		cookie, _ := r.Cookie(cookies.CSRFToken)
+		header := r.Header.Get(cookies.CSRFHeader)
+		if cookie == nil || cookie.Value == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(header)) != 1 {
+			problem.Write(w, r, http.StatusForbidden, problem.CodeCSRFTokenInvalid, "Missing or invalid CSRF token")
+			return
+		}
+
+		handlerFunc(w, r)
+	})
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// Запрос авторизован куки, если нет заголовка Authorization, но есть куки с токеном
+func usesCookieAuth(r *http.Request) bool {
+	if r.Header.Get("Authorization") != "" {
+		return false
+	}
+	for _, name := range []string{cookies.AccessToken, cookies.RefreshToken} {
+		if cookie, _ := r.Cookie(name); cookie != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package csrfhandler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/cookies"
+	"github.com/stretchr/testify/assert"
+)
+
+// Тестирование проверки CSRF-токена
+func TestCSRFHandle(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		cookies  map[string]string
+		header   map[string]string
+		wantCode int
+	}{
+		{
+			name:     "cookie auth with matching token",
+			method:   http.MethodPost,
+			cookies:  map[string]string{cookies.AccessToken: "jwt", cookies.CSRFToken: "csrf"},
+			header:   map[string]string{cookies.CSRFHeader: "csrf"},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "cookie auth without header",
+			method:   http.MethodPost,
+			cookies:  map[string]string{cookies.AccessToken: "jwt", cookies.CSRFToken: "csrf"},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "cookie auth with wrong token",
+			method:   http.MethodPost,
+			cookies:  map[string]string{cookies.AccessToken: "jwt", cookies.CSRFToken: "csrf"},
+			header:   map[string]string{cookies.CSRFHeader: "other"},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "cookie auth without csrf cookie",
+			method:   http.MethodPost,
+			cookies:  map[string]string{cookies.RefreshToken: "refresh"},
+			header:   map[string]string{cookies.CSRFHeader: ""},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "bearer auth",
+			method:   http.MethodPost,
+			cookies:  map[string]string{cookies.AccessToken: "jwt"},
+			header:   map[string]string{"Authorization": "Bearer jwt"},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "safe method",
+			method:   http.MethodGet,
+			cookies:  map[string]string{cookies.AccessToken: "jwt"},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "no credentials",
+			method:   http.MethodPost,
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := CSRFHandle(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			request := httptest.NewRequest(tt.method, "/api/user/orders", nil)
+			for name, value := range tt.cookies {
+				request.AddCookie(&http.Cookie{Name: name, Value: value})
+			}
+			for name, value := range tt.header {
+				request.Header.Set(name, value)
+			}
+			response := httptest.NewRecorder()
+
+			handler(response, request)
+
+			res := response.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.wantCode, res.StatusCode)
+		})
+	}
+}
@@ -6,6 +6,8 @@ import (
 
 	"go.uber.org/zap"
 	"github.com/dsemenov12/loyalty-gofermart/internal/logger"
+	"github.com/dsemenov12/loyalty-gofermart/internal/middlewares/requestidhandler"
+	"github.com/dsemenov12/loyalty-gofermart/internal/tracing"
 )
 
 type (
@@ -48,11 +50,14 @@ func RequestLogger(handlerFunc http.HandlerFunc) http.HandlerFunc {
 		duration := time.Since(start)
 		
 		logger.Log.Info("got incoming HTTP request",
+            zap.String("request_id", requestidhandler.FromContext(r.Context())),
+            zap.String("trace_id", tracing.TraceID(r.Context())),
             zap.String("method", r.Method),
             zap.String("path", r.URL.Path),
 			zap.Duration("duration", duration),
         )
 		logger.Log.Info("got response",
+            zap.String("request_id", requestidhandler.FromContext(r.Context())),
             zap.Int("status", responseData.status),
 			zap.Int("size", responseData.size),
         )
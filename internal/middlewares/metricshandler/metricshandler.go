@@ -0,0 +1,55 @@
+package metricshandler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/metrics"
+	"github.com/go-chi/chi/v5"
+)
+
+// Маршрут запросов, не сопоставленных ни с одним обработчиком
+const unmatchedRoute = "unmatched"
+
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusResponseWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *statusResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Учитывает количество и длительность запросов по шаблону маршрута chi и статусу ответа.
+// Шаблон вместо пути не даёт номерам заказов и идентификаторам пользователей раздувать число серий
+func MetricsHandle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusResponseWriter{ResponseWriter: w}
+
+		next.ServeHTTP(sw, r)
+
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		metrics.HTTPRequests.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,46 @@
+package metricshandler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/metrics"
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// Тестирование учёта запросов по шаблону маршрута и статусу ответа
+func TestMetricsHandle(t *testing.T) {
+	router := chi.NewRouter()
+	router.Use(MetricsHandle)
+	router.Get("/api/admin/orders/{number}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	router.Get("/api/user/balance", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	})
+
+	tests := []struct {
+		name   string
+		path   string
+		route  string
+		status string
+	}{
+		{name: "route with parameter", path: "/api/admin/orders/2377225624", route: "/api/admin/orders/{number}", status: "404"},
+		{name: "implicit status", path: "/api/user/balance", route: "/api/user/balance", status: "200"},
+		{name: "unknown path", path: "/api/unknown", route: unmatchedRoute, status: "404"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := metrics.HTTPRequests.WithLabelValues(http.MethodGet, tt.route, tt.status)
+			before := testutil.ToFloat64(counter)
+
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, before+1, testutil.ToFloat64(counter))
+		})
+	}
+}
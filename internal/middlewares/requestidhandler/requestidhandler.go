@@ -0,0 +1,59 @@
+package requestidhandler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// Заголовок с идентификатором запроса
+const HeaderName = "X-Request-ID"
+
+// Максимальная длина идентификатора, принимаемого от клиента
+const maxLength = 128
+
+type requestIDContextKey struct{}
+
+// Присваивает запросу идентификатор для сопоставления логов и ответов об ошибках.
+// Идентификатор берётся из заголовка X-Request-ID либо генерируется
+func RequestIDHandle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(HeaderName)
+		if !isValid(requestID) {
+			requestID = generate()
+		}
+
+		w.Header().Set(HeaderName, requestID)
+		ctx := context.WithValue(r.Context(), requestIDContextKey{}, requestID)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Идентификатор текущего запроса либо пустая строка
+func FromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestID
+}
+
+// Принимаются только короткие идентификаторы из печатных ASCII-символов
+func isValid(requestID string) bool {
+	if requestID == "" || len(requestID) > maxLength {
+		return false
+	}
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] < 0x21 || requestID[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func generate() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
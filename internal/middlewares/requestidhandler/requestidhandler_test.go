@@ -0,0 +1,48 @@
+package requestidhandler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDHandle(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestID string
+		keep      bool
+	}{
+		{name: "id from client", requestID: "abc-123", keep: true},
+		{name: "generated id", requestID: "", keep: false},
+		{name: "too long id", requestID: strings.Repeat("a", 129), keep: false},
+		{name: "id with spaces", requestID: "abc 123", keep: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fromContext string
+			handler := RequestIDHandle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fromContext = FromContext(r.Context())
+			}))
+
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.requestID != "" {
+				request.Header.Set(HeaderName, tt.requestID)
+			}
+			response := httptest.NewRecorder()
+
+			handler.ServeHTTP(response, request)
+
+			assert.NotEmpty(t, fromContext)
+			assert.Equal(t, fromContext, response.Header().Get(HeaderName))
+			if tt.keep {
+				assert.Equal(t, tt.requestID, fromContext)
+			} else {
+				assert.NotEqual(t, tt.requestID, fromContext)
+			}
+		})
+	}
+}
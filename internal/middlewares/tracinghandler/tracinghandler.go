@@ -0,0 +1,63 @@
+package tracinghandler
+
+import (
+	"net/http"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/middlewares/requestidhandler"
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Маршрут запросов, не сопоставленных ни с одним обработчиком
+const unmatchedRoute = "unmatched"
+
+// Заголовки распространения контекста трассы (W3C Trace Context и Baggage)
+var propagationHeaders = []string{"traceparent", "tracestate", "baggage"}
+
+// Пока доверие не настроено, входящий контекст трассы отбрасывается
+var trustIncoming bool
+
+// Задаёт, продолжать ли трассу из заголовков запроса. Включается, только если запросы
+// приходят через доверенный прокси, иначе клиент сам решает, какие трассы записывать
+func Initialize(trust bool) {
+	trustIncoming = trust
+}
+
+// Открывает серверный спан на каждый запрос. Трасса продолжается из заголовка traceparent,
+// только если входящему контексту доверяют, иначе начинается новая и сэмплируется сервисом.
+// Спан называется по шаблону маршрута chi, который известен только после маршрутизации
+func TracingHandle(next http.Handler) http.Handler {
+	named := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(
+			semconv.HTTPRoute(route),
+			attribute.String("request.id", requestidhandler.FromContext(r.Context())),
+		)
+	})
+
+	traced := otelhttp.NewHandler(named, "http.request",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method
+		}),
+	)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !trustIncoming {
+			for _, header := range propagationHeaders {
+				r.Header.Del(header)
+			}
+		}
+		traced.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,90 @@
+package tracinghandler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Подмена глобального провайдера трассировки и пропагатора и доверия входящему контексту на время теста
+func useTracing(t *testing.T, trust bool) *tracetest.SpanRecorder {
+	previousProvider := otel.GetTracerProvider()
+	previousPropagator := otel.GetTextMapPropagator()
+	previousTrust := trustIncoming
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+		trustIncoming = previousTrust
+	})
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	Initialize(trust)
+	return recorder
+}
+
+// Запрос с контекстом трассы клиента через роутер с TracingHandle
+func serveTraced(t *testing.T, handlerFunc http.HandlerFunc) {
+	router := chi.NewRouter()
+	router.Use(TracingHandle)
+	router.Get("/api/admin/orders/{number}", handlerFunc)
+
+	request := httptest.NewRequest(http.MethodGet, "/api/admin/orders/2377225624", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	request.Header.Set("baggage", "tenant=other")
+	router.ServeHTTP(httptest.NewRecorder(), request)
+}
+
+// Тестирование имени спана по шаблону маршрута и продолжения входящей трассы от доверенного прокси
+func TestTracingHandle(t *testing.T) {
+	recorder := useTracing(t, true)
+
+	var handlerSpan trace.SpanContext
+	serveTraced(t, func(w http.ResponseWriter, r *http.Request) {
+		handlerSpan = trace.SpanContextFromContext(r.Context())
+	})
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	span := spans[0]
+
+	assert.Equal(t, "GET /api/admin/orders/{number}", span.Name())
+	assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+	assert.Equal(t, span.SpanContext(), handlerSpan)
+	assert.Contains(t, span.Attributes(), semconv.HTTPRoute("/api/admin/orders/{number}"))
+}
+
+// Тестирование того, что без доверия входящему контексту клиент не управляет трассой
+func TestTracingHandle_Untrusted(t *testing.T) {
+	recorder := useTracing(t, false)
+
+	var traceparent, bag string
+	serveTraced(t, func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		bag = baggage.FromContext(r.Context()).String()
+	})
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	span := spans[0]
+
+	// Трасса начинается заново, а не продолжает трассу клиента
+	assert.NotEqual(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	assert.False(t, span.Parent().IsValid())
+	assert.Empty(t, traceparent)
+	assert.Empty(t, bag)
+}
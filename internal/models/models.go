@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/money"
+)
 
 type UserRegister struct {
 	Login    string `json:"login"`
@@ -13,43 +17,198 @@ type UserAuth struct {
 }
 
 type User struct {
-	ID       int
-	Login    string
-	Password string
+	ID          int
+	Login       string
+	Password    string
+	Role        string
+	TOTPEnabled bool
+}
+
+// Настройки двухфакторной аутентификации
+type TOTP struct {
+	Secret   string // в том виде, как хранится в БД; расшифровывается auth.OpenTOTPSecret
+	Enabled  bool
+	LastStep int64 // шаг последнего принятого кода
+}
+
+// Сессия пользователя: связывает access-токены и цепочку refresh-токенов
+type Session struct {
+	ID        string
+	UserID    int
+	Role      string // роль пользователя на момент выпуска токенов
+	ExpiresAt time.Time
+}
+
+// Токены сессии, выдаваемые при входе, регистрации и обновлении
+type TokenResponse struct {
+	Token        string `json:"token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+type PasswordResetRequest struct {
+	Login string `json:"login"`
+}
+
+type PasswordResetConfirm struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type TwoFactorSetupResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type TwoFactorDisableRequest struct {
+	Password     string `json:"password"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// Ответ на первый шаг входа, если включена двухфакторная аутентификация
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int    `json:"expires_in"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
 }
 
 type Order struct {
-	Number     string
-	Status     string
-	Accrual    float64
-	UploadedAt time.Time
+	UserID       int
+	Number       string
+	Status       string
+	Accrual      money.Amount
+	UploadedAt   time.Time
+	PollAttempts int // опросы системы начислений, не давшие окончательного статуса
 }
 
 type OrderResponse struct {
 	Number     string  `json:"number"`
 	Status     string  `json:"status"`
-	Accrual    float64 `json:"accrual,omitempty"`
+	Accrual    *money.Amount `json:"accrual,omitempty"`
 	UploadedAt string  `json:"uploaded_at"`
 }
 
+// Заказ в ответах API администратора
+type AdminOrderResponse struct {
+	UserID int `json:"user_id"`
+	OrderResponse
+}
+
+// Профиль пользователя в выгрузке данных и ответах API администратора
+type UserProfile struct {
+	ID               int    `json:"id"`
+	Login            string `json:"login"`
+	Role             string `json:"role"`
+	TwoFactorEnabled bool   `json:"two_factor_enabled"`
+}
+
+// Ручная корректировка баланса. Положительная сумма начисляет баллы, отрицательная списывает
+type BalanceAdjustment struct {
+	ID         int
+	UserID     int
+	OperatorID int
+	Amount     money.Amount
+	Reason     string
+	// Ключ идемпотентности, переданный оператором. Пустой, если не передавался
+	IdempotencyKey string
+	CreatedAt      time.Time
+}
+
+type BalanceAdjustmentRequest struct {
+	Amount money.Amount `json:"amount"`
+	Reason string       `json:"reason"`
+}
+
+type BalanceAdjustmentResponse struct {
+	ID         int          `json:"id"`
+	UserID     int          `json:"user_id"`
+	OperatorID int          `json:"operator_id"`
+	Amount     money.Amount `json:"amount"`
+	Reason     string       `json:"reason"`
+	CreatedAt  string       `json:"created_at"`
+}
+
+// Судьба остатка баланса при удалении учётной записи
+const (
+	BalancePolicyForfeit = "forfeit" // остаток сгорает: проводка FORFEIT переводит его на счёт сгоревших баллов
+	BalancePolicyArchive = "archive" // остаток остаётся на счёте удалённого пользователя
+)
+
+type DeleteAccountRequest struct {
+	Password     string `json:"password"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// Выгрузка данных пользователя
+type UserDataExport struct {
+	ExportedAt     string          `json:"exported_at"`
+	Profile        UserProfile     `json:"profile"`
+	Balance        Balance         `json:"balance"`
+	Orders         []OrderResponse `json:"orders"`
+	Withdrawals    []Withdrawal    `json:"withdrawals"`
+	BalanceHistory []Posting       `json:"balance_history"`
+}
+
 type Balance struct {
-	Current   float64 `json:"current"`
-	Withdrawn float64 `json:"withdrawn"`
+	Current   money.Amount `json:"current"`
+	Withdrawn money.Amount `json:"withdrawn"`
 }
 
 type WithdrawRequest struct {
 	Order string  `json:"order"`
-	Sum   float64 `json:"sum"`
+	Sum   money.Amount `json:"sum"`
 }
 
 type Withdrawal struct {
     Order       string  `json:"order"`
-    Sum         float64 `json:"sum"`
+    Sum         money.Amount `json:"sum"`
     ProcessedAt string  `json:"processed_at"`
 }
 
 type AccrualInfo struct {
 	OrderNumber string  `json:"order"`
 	Status      string  `json:"status"`
-	Accrual     float64 `json:"accrual"`
-}
\ No newline at end of file
+	Accrual     money.Amount `json:"accrual"`
+}
+
+// Виды проводок главной книги
+const (
+	PostingAccrual    = "ACCRUAL"
+	PostingWithdrawal = "WITHDRAWAL"
+	PostingAdjustment = "ADJUSTMENT"
+	PostingForfeit    = "FORFEIT"
+)
+
+// Движение по счёту пользователя
+type Posting struct {
+	Kind        string       `json:"kind"`
+	Amount      money.Amount `json:"amount"`
+	Reference   string       `json:"reference,omitempty"`
+	ProcessedAt string       `json:"processed_at"`
+}
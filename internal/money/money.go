@@ -0,0 +1,252 @@
+// Пакет money реализует денежную сумму с фиксированной точностью в два знака после запятой,
+// соответствующую колонкам DECIMAL(10, 2) в БД. Все денежные колонки, включая главную книгу,
+// объявлены с той же точностью, иначе значение из БД может не поместиться в Amount.
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Количество знаков после запятой
+const scale = 2
+
+// Множитель для перевода в сотые доли
+const centsPerUnit = 100
+
+// Количество цифр целой части в DECIMAL(10, 2)
+const intDigits = 8
+
+// Максимальное значение, помещающееся в DECIMAL(10, 2)
+const maxCents = 99999999_99
+
+var (
+	ErrOverflow = errors.New("money: amount out of range")
+	ErrSyntax   = errors.New("money: invalid amount")
+)
+
+// Денежная сумма в сотых долях. Нулевое значение соответствует нулю
+type Amount struct {
+	cents int64
+}
+
+// Создаёт сумму из количества сотых долей
+func FromCents(cents int64) (Amount, error) {
+	if cents > maxCents || cents < -maxCents {
+		return Amount{}, ErrOverflow
+	}
+	return Amount{cents: cents}, nil
+}
+
+// Создаёт сумму из числа с плавающей точкой, округляя до сотых половину от нуля
+func FromFloat(f float64) (Amount, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Amount{}, ErrSyntax
+	}
+	cents := math.Round(f * centsPerUnit)
+	if cents > maxCents || cents < -maxCents {
+		return Amount{}, ErrOverflow
+	}
+	return Amount{cents: int64(cents)}, nil
+}
+
+// Разбирает десятичную запись суммы без потери точности.
+// Разряды после сотых округляются половиной от нуля: 0.005 -> 0.01, -0.005 -> -0.01
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+
+	negative := false
+	switch {
+	case strings.HasPrefix(s, "-"):
+		negative = true
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+
+	// Экспоненциальная запись допустима в JSON, разбираем её через float64
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return Amount{}, ErrSyntax
+		}
+		if negative {
+			f = -f
+		}
+		return FromFloat(f)
+	}
+
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	if intPart == "" && fracPart == "" || !isDigits(intPart) || !isDigits(fracPart) {
+		return Amount{}, ErrSyntax
+	}
+
+	intPart = strings.TrimLeft(intPart, "0")
+	if len(intPart) > intDigits {
+		return Amount{}, ErrOverflow
+	}
+
+	var cents int64
+	for _, c := range intPart {
+		cents = cents*10 + int64(c-'0')
+	}
+	for i := 0; i < scale; i++ {
+		cents *= 10
+		if i < len(fracPart) {
+			cents += int64(fracPart[i] - '0')
+		}
+	}
+	if len(fracPart) > scale && fracPart[scale] >= '5' {
+		cents++
+	}
+
+	if negative {
+		cents = -cents
+	}
+
+	return FromCents(cents)
+}
+
+// Разбирает сумму и паникует при ошибке. Предназначена для констант и тестов
+func MustParse(s string) Amount {
+	a, err := Parse(s)
+	if err != nil {
+		panic(fmt.Sprintf("money: parse %q: %v", s, err))
+	}
+	return a
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Количество сотых долей
+func (a Amount) Cents() int64 {
+	return a.cents
+}
+
+// Значение в виде float64 для метрик и логов, не для вычислений
+func (a Amount) Float64() float64 {
+	return float64(a.cents) / centsPerUnit
+}
+
+func (a Amount) IsZero() bool {
+	return a.cents == 0
+}
+
+func (a Amount) IsPositive() bool {
+	return a.cents > 0
+}
+
+func (a Amount) IsNegative() bool {
+	return a.cents < 0
+}
+
+// Сравнивает суммы: -1, если a < b, 0, если равны, и +1, если a > b
+func (a Amount) Cmp(b Amount) int {
+	switch {
+	case a.cents < b.cents:
+		return -1
+	case a.cents > b.cents:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Сумма с проверкой переполнения
+func (a Amount) Add(b Amount) (Amount, error) {
+	return FromCents(a.cents + b.cents)
+}
+
+// Разность с проверкой переполнения
+func (a Amount) Sub(b Amount) (Amount, error) {
+	return FromCents(a.cents - b.cents)
+}
+
+func (a Amount) Neg() Amount {
+	return Amount{cents: -a.cents}
+}
+
+// Запись с двумя знаками после запятой, например 729.98 или 42.00
+func (a Amount) String() string {
+	sign := ""
+	cents := a.cents
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/centsPerUnit, cents%centsPerUnit)
+}
+
+// Число в JSON без незначащих нулей, как в спецификации: 500.5, 42, 729.98
+func (a Amount) MarshalJSON() ([]byte, error) {
+	s := a.String()
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+	return []byte(s), nil
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	// Сумма должна передаваться числом
+	if strings.HasPrefix(s, `"`) {
+		return ErrSyntax
+	}
+
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// Чтение значения DECIMAL из БД
+func (a *Amount) Scan(src any) error {
+	var (
+		parsed Amount
+		err    error
+	)
+
+	switch v := src.(type) {
+	case nil:
+		parsed = Amount{}
+	case string:
+		parsed, err = Parse(v)
+	case []byte:
+		parsed, err = Parse(string(v))
+	case int64:
+		if v > maxCents/centsPerUnit || v < -maxCents/centsPerUnit {
+			return ErrOverflow
+		}
+		parsed = Amount{cents: v * centsPerUnit}
+	case float64:
+		parsed, err = FromFloat(v)
+	default:
+		return fmt.Errorf("money: cannot scan %T", src)
+	}
+	if err != nil {
+		return err
+	}
+
+	*a = parsed
+	return nil
+}
+
+// Запись значения в БД в виде десятичной строки
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
@@ -0,0 +1,129 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Тестирование разбора десятичной записи
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    int64
+		wantErr error
+	}{
+		{name: "integer", value: "500", want: 50000},
+		{name: "one digit fraction", value: "500.5", want: 50050},
+		{name: "two digit fraction", value: "729.98", want: 72998},
+		{name: "fraction only", value: ".5", want: 50},
+		{name: "negative", value: "-42.1", want: -4210},
+		{name: "round half up", value: "0.005", want: 1},
+		{name: "round down", value: "0.0049", want: 0},
+		{name: "round half away from zero", value: "-0.005", want: -1},
+		{name: "exponent", value: "1.5e2", want: 15000},
+		{name: "max value", value: "99999999.99", want: 9999999999},
+		{name: "overflow", value: "100000000", wantErr: ErrOverflow},
+		{name: "overflow after rounding", value: "99999999.995", wantErr: ErrOverflow},
+		{name: "empty", value: "", wantErr: ErrSyntax},
+		{name: "letters", value: "12a", wantErr: ErrSyntax},
+		{name: "two dots", value: "1.2.3", wantErr: ErrSyntax},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.value)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.Cents())
+		})
+	}
+}
+
+// Тестирование точности сложения
+func TestAmount_Add(t *testing.T) {
+	sum, err := MustParse("0.1").Add(MustParse("0.2"))
+	assert.NoError(t, err)
+	assert.Equal(t, MustParse("0.3"), sum)
+
+	_, err = MustParse("99999999.99").Add(MustParse("0.01"))
+	assert.ErrorIs(t, err, ErrOverflow)
+
+	diff, err := MustParse("10").Sub(MustParse("10.01"))
+	assert.NoError(t, err)
+	assert.True(t, diff.IsNegative())
+	assert.Equal(t, -1, diff.Cmp(Amount{}))
+}
+
+// Тестирование сериализации в JSON
+func TestAmount_JSON(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "500.5", want: "500.5"},
+		{value: "42", want: "42"},
+		{value: "729.98", want: "729.98"},
+		{value: "0", want: "0"},
+		{value: "-0.5", want: "-0.5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			data, err := json.Marshal(MustParse(tt.value))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(data))
+
+			var got Amount
+			assert.NoError(t, json.Unmarshal(data, &got))
+			assert.Equal(t, MustParse(tt.value), got)
+		})
+	}
+
+	var got Amount
+	assert.Error(t, json.Unmarshal([]byte(`"100"`), &got))
+}
+
+// Тестирование чтения значений из БД
+func TestAmount_Scan(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     any
+		want    Amount
+		wantErr bool
+	}{
+		{name: "string", src: "751.00", want: MustParse("751")},
+		{name: "bytes", src: []byte("0.10"), want: MustParse("0.1")},
+		{name: "int64", src: int64(42), want: MustParse("42")},
+		{name: "float64", src: 0.1 + 0.2, want: MustParse("0.3")},
+		{name: "nil", src: nil, want: Amount{}},
+		{name: "max value", src: "99999999.99", want: MustParse("99999999.99")},
+		{name: "min value", src: []byte("-99999999.99"), want: MustParse("-99999999.99")},
+		{name: "max int64", src: int64(99999999), want: MustParse("99999999")},
+		{name: "out of range", src: "100000000.00", wantErr: true},
+		{name: "int64 out of range", src: int64(100000000), wantErr: true},
+		{name: "unsupported type", src: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Amount
+			err := got.Scan(tt.src)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	value, err := MustParse("500.5").Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "500.50", value)
+}
@@ -0,0 +1,85 @@
+// Пакет notify доставляет пользователям служебные сообщения.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Тип сообщения со ссылкой на сброс пароля
+const TypePasswordReset = "password_reset"
+
+// Тайм-аут запроса к сервису уведомлений
+const webhookTimeout = 10 * time.Second
+
+// Отправка сообщений пользователю
+type Sender interface {
+	SendPasswordReset(ctx context.Context, login, token string) error
+}
+
+// Сообщение, передаваемое сервису уведомлений
+type Message struct {
+	Type  string `json:"type"`
+	Login string `json:"login"`
+	Token string `json:"token"`
+}
+
+// Передаёт сообщения внешнему сервису уведомлений POST-запросом с JSON-телом.
+// Сервис сам находит контакты пользователя по логину и доставляет письмо или SMS
+type WebhookSender struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// Создаёт отправителя. Непустой token передаётся в заголовке Authorization
+func NewWebhookSender(url, token string) *WebhookSender {
+	return &WebhookSender{
+		url:   url,
+		token: token,
+		client: &http.Client{
+			Timeout:   webhookTimeout,
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+		},
+	}
+}
+
+func (s *WebhookSender) SendPasswordReset(ctx context.Context, login, token string) error {
+	return s.send(ctx, Message{Type: TypePasswordReset, Login: login, Token: token})
+}
+
+// Отправка сообщения. Ответ со статусом вне 2xx считается ошибкой доставки
+func (s *WebhookSender) send(ctx context.Context, message Message) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send %s notification: %w", message.Type, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("send %s notification: unexpected status %d", message.Type, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тестирование отправки токена сброса пароля сервису уведомлений
+func TestWebhookSender_SendPasswordReset(t *testing.T) {
+	var received Message
+	var authorization string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		authorization = r.Header.Get("Authorization")
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	sender := NewWebhookSender(ts.URL, "secret")
+	require.NoError(t, sender.SendPasswordReset(context.Background(), "user", "reset-token"))
+
+	assert.Equal(t, Message{Type: TypePasswordReset, Login: "user", Token: "reset-token"}, received)
+	assert.Equal(t, "Bearer secret", authorization)
+}
+
+// Тестирование ошибки доставки при ответе сервиса уведомлений вне 2xx
+func TestWebhookSender_SendPasswordReset_Error(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	err := NewWebhookSender(ts.URL, "").SendPasswordReset(context.Background(), "user", "reset-token")
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "reset-token")
+}
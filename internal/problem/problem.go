@@ -0,0 +1,97 @@
+// Пакет problem формирует ответы об ошибках в формате RFC 7807 (application/problem+json).
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/middlewares/requestidhandler"
+)
+
+const ContentType = "application/problem+json"
+
+// Префикс URI типа ошибки, к которому добавляется код
+const typePrefix = "urn:gophermart:problem:"
+
+// Стабильные коды ошибок, на которые может опираться клиент
+const (
+	CodeInvalidRequest        = "invalid_request"
+	CodeValidationFailed      = "validation_failed"
+	CodeUnauthorized          = "unauthorized"
+	CodeForbidden             = "forbidden"
+	CodeInvalidCredentials    = "invalid_credentials"
+	CodeSessionRevoked        = "session_revoked"
+	CodeInvalidRefreshToken   = "invalid_refresh_token"
+	CodeCSRFTokenInvalid      = "csrf_token_invalid"
+	CodeTooManyLoginAttempts  = "too_many_login_attempts"
+	CodeInvalidResetToken     = "invalid_reset_token"
+	CodeTwoFactorEnabled      = "two_factor_already_enabled"
+	CodeTwoFactorNotEnabled   = "two_factor_not_enabled"
+	CodeInvalidTwoFactorCode  = "invalid_two_factor_code"
+	CodeTwoFactorUnavailable  = "two_factor_unavailable"
+	CodeUserExists            = "user_exists"
+	CodeUserNotFound          = "user_not_found"
+	CodeInvalidOrderNumber    = "invalid_order_number"
+	CodeOrderOwnedByAnother   = "order_owned_by_another_user"
+	CodeOrderNotFound         = "order_not_found"
+	CodeInsufficientFunds     = "insufficient_funds"
+	CodeOrderAlreadyWithdrawn = "order_already_withdrawn"
+	CodeIdempotencyKeyReused  = "idempotency_key_reused"
+	CodeInternal              = "internal_error"
+)
+
+// Коды ошибок валидации отдельных полей
+const (
+	FieldRequired = "required"
+	FieldInvalid  = "invalid"
+	FieldTooLong  = "too_long"
+	FieldTooShort = "too_short"
+	FieldTooWeak  = "too_weak"
+	FieldBreached = "breached"
+)
+
+// Тело ответа об ошибке
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// Ошибка валидации поля запроса
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Записывает ответ об ошибке
+func Write(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	write(w, r, Problem{Status: status, Code: code, Detail: detail})
+}
+
+// Записывает ответ об ошибках валидации полей
+func WriteValidation(w http.ResponseWriter, r *http.Request, status int, errors []FieldError) {
+	write(w, r, Problem{
+		Status: status,
+		Code:   CodeValidationFailed,
+		Detail: "Request validation failed",
+		Errors: errors,
+	})
+}
+
+func write(w http.ResponseWriter, r *http.Request, p Problem) {
+	p.Type = typePrefix + p.Code
+	p.Title = http.StatusText(p.Status)
+	p.Instance = r.URL.Path
+	p.RequestID = requestidhandler.FromContext(r.Context())
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
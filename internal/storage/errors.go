@@ -0,0 +1,27 @@
+package storage
+
+import "errors"
+
+// Ошибки хранилища. Реализации возвращают их напрямую либо обёрнутыми
+// вместе с исходной причиной, поэтому проверять их следует через errors.Is
+var (
+	ErrUserExists              = errors.New("user already exists")
+	ErrUserNotFound            = errors.New("user not found")
+	ErrNoUserInContext         = errors.New("user id not found in context")
+	ErrOrderExistsForUser      = errors.New("order already exists for the same user")
+	ErrOrderExistsForAnother   = errors.New("order already exists for another user")
+	ErrOrderNotFound           = errors.New("order not found")
+	ErrInsufficientFunds       = errors.New("insufficient funds")
+	ErrOrderAlreadyWithdrawn   = errors.New("order already used for withdrawal")
+	ErrIdempotencyKeyReused    = errors.New("idempotency key reused")
+	ErrOrderStatusNotFinal     = errors.New("order status is not final")
+	ErrUnbalancedLedgerPosting = errors.New("unbalanced ledger transaction")
+	ErrSessionNotFound         = errors.New("session not found")
+	ErrSessionRevoked          = errors.New("session revoked or expired")
+	ErrRefreshTokenReused      = errors.New("refresh token reused")
+	ErrResetTokenInvalid       = errors.New("password reset token is invalid or expired")
+	ErrTOTPAlreadyEnabled      = errors.New("two-factor authentication already enabled")
+	ErrTOTPCodeReused          = errors.New("two-factor code already used")
+	ErrRecoveryCodeInvalid     = errors.New("recovery code is invalid or used")
+	ErrChallengeInvalid        = errors.New("two-factor challenge is invalid, used or expired")
+)
@@ -7,8 +7,10 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	models "github.com/dsemenov12/loyalty-gofermart/internal/models"
+	money "github.com/dsemenov12/loyalty-gofermart/internal/money"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -35,6 +37,91 @@ func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
 	return m.recorder
 }
 
+// AdjustBalance mocks base method.
+func (m *MockStorage) AdjustBalance(ctx context.Context, adjustment models.BalanceAdjustment) (*models.BalanceAdjustment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdjustBalance", ctx, adjustment)
+	ret0, _ := ret[0].(*models.BalanceAdjustment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdjustBalance indicates an expected call of AdjustBalance.
+func (mr *MockStorageMockRecorder) AdjustBalance(ctx, adjustment interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdjustBalance", reflect.TypeOf((*MockStorage)(nil).AdjustBalance), ctx, adjustment)
+}
+
+// CheckTwoFactorChallenge mocks base method.
+func (m *MockStorage) CheckTwoFactorChallenge(ctx context.Context, challengeID string, userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckTwoFactorChallenge", ctx, challengeID, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckTwoFactorChallenge indicates an expected call of CheckTwoFactorChallenge.
+func (mr *MockStorageMockRecorder) CheckTwoFactorChallenge(ctx, challengeID, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckTwoFactorChallenge", reflect.TypeOf((*MockStorage)(nil).CheckTwoFactorChallenge), ctx, challengeID, userID)
+}
+
+// ConsumeTwoFactorChallenge mocks base method.
+func (m *MockStorage) ConsumeTwoFactorChallenge(ctx context.Context, challengeID string, userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeTwoFactorChallenge", ctx, challengeID, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ConsumeTwoFactorChallenge indicates an expected call of ConsumeTwoFactorChallenge.
+func (mr *MockStorageMockRecorder) ConsumeTwoFactorChallenge(ctx, challengeID, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeTwoFactorChallenge", reflect.TypeOf((*MockStorage)(nil).ConsumeTwoFactorChallenge), ctx, challengeID, userID)
+}
+
+// CreatePasswordResetToken mocks base method.
+func (m *MockStorage) CreatePasswordResetToken(ctx context.Context, userID int, tokenHash string, ttl time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePasswordResetToken", ctx, userID, tokenHash, ttl)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreatePasswordResetToken indicates an expected call of CreatePasswordResetToken.
+func (mr *MockStorageMockRecorder) CreatePasswordResetToken(ctx, userID, tokenHash, ttl interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePasswordResetToken", reflect.TypeOf((*MockStorage)(nil).CreatePasswordResetToken), ctx, userID, tokenHash, ttl)
+}
+
+// CreateSession mocks base method.
+func (m *MockStorage) CreateSession(ctx context.Context, session models.Session, refreshTokenHash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSession", ctx, session, refreshTokenHash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateSession indicates an expected call of CreateSession.
+func (mr *MockStorageMockRecorder) CreateSession(ctx, session, refreshTokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockStorage)(nil).CreateSession), ctx, session, refreshTokenHash)
+}
+
+// CreateTwoFactorChallenge mocks base method.
+func (m *MockStorage) CreateTwoFactorChallenge(ctx context.Context, challengeID string, userID int, ttl time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTwoFactorChallenge", ctx, challengeID, userID, ttl)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateTwoFactorChallenge indicates an expected call of CreateTwoFactorChallenge.
+func (mr *MockStorageMockRecorder) CreateTwoFactorChallenge(ctx, challengeID, userID, ttl interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTwoFactorChallenge", reflect.TypeOf((*MockStorage)(nil).CreateTwoFactorChallenge), ctx, challengeID, userID, ttl)
+}
+
 // CreateUser mocks base method.
 func (m *MockStorage) CreateUser(ctx context.Context, login, hashedPassword string) error {
 	m.ctrl.T.Helper()
@@ -49,6 +136,48 @@ func (mr *MockStorageMockRecorder) CreateUser(ctx, login, hashedPassword interfa
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockStorage)(nil).CreateUser), ctx, login, hashedPassword)
 }
 
+// DeleteUser mocks base method.
+func (m *MockStorage) DeleteUser(ctx context.Context, userID int, balancePolicy string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, userID, balancePolicy)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockStorageMockRecorder) DeleteUser(ctx, userID, balancePolicy interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockStorage)(nil).DeleteUser), ctx, userID, balancePolicy)
+}
+
+// DisableTOTP mocks base method.
+func (m *MockStorage) DisableTOTP(ctx context.Context, userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisableTOTP", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DisableTOTP indicates an expected call of DisableTOTP.
+func (mr *MockStorageMockRecorder) DisableTOTP(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableTOTP", reflect.TypeOf((*MockStorage)(nil).DisableTOTP), ctx, userID)
+}
+
+// EnableTOTP mocks base method.
+func (m *MockStorage) EnableTOTP(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnableTOTP", ctx, userID, step, recoveryCodeHashes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnableTOTP indicates an expected call of EnableTOTP.
+func (mr *MockStorageMockRecorder) EnableTOTP(ctx, userID, step, recoveryCodeHashes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnableTOTP", reflect.TypeOf((*MockStorage)(nil).EnableTOTP), ctx, userID, step, recoveryCodeHashes)
+}
+
 // GetBalance mocks base method.
 func (m *MockStorage) GetBalance(ctx context.Context) (*models.Balance, error) {
 	m.ctrl.T.Helper()
@@ -64,6 +193,86 @@ func (mr *MockStorageMockRecorder) GetBalance(ctx interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalance", reflect.TypeOf((*MockStorage)(nil).GetBalance), ctx)
 }
 
+// GetBalanceAdjustments mocks base method.
+func (m *MockStorage) GetBalanceAdjustments(ctx context.Context, userID int) ([]models.BalanceAdjustment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBalanceAdjustments", ctx, userID)
+	ret0, _ := ret[0].([]models.BalanceAdjustment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBalanceAdjustments indicates an expected call of GetBalanceAdjustments.
+func (mr *MockStorageMockRecorder) GetBalanceAdjustments(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalanceAdjustments", reflect.TypeOf((*MockStorage)(nil).GetBalanceAdjustments), ctx, userID)
+}
+
+// GetBalanceByUserID mocks base method.
+func (m *MockStorage) GetBalanceByUserID(ctx context.Context, userID int) (*models.Balance, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBalanceByUserID", ctx, userID)
+	ret0, _ := ret[0].(*models.Balance)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBalanceByUserID indicates an expected call of GetBalanceByUserID.
+func (mr *MockStorageMockRecorder) GetBalanceByUserID(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalanceByUserID", reflect.TypeOf((*MockStorage)(nil).GetBalanceByUserID), ctx, userID)
+}
+
+// GetBalanceHistory mocks base method.
+func (m *MockStorage) GetBalanceHistory(ctx context.Context) ([]models.Posting, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBalanceHistory", ctx)
+	ret0, _ := ret[0].([]models.Posting)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBalanceHistory indicates an expected call of GetBalanceHistory.
+func (mr *MockStorageMockRecorder) GetBalanceHistory(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalanceHistory", reflect.TypeOf((*MockStorage)(nil).GetBalanceHistory), ctx)
+}
+
+// GetLoginLockout mocks base method.
+func (m *MockStorage) GetLoginLockout(ctx context.Context, keys ...string) (time.Duration, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx}
+	for _, a := range keys {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetLoginLockout", varargs...)
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoginLockout indicates an expected call of GetLoginLockout.
+func (mr *MockStorageMockRecorder) GetLoginLockout(ctx interface{}, keys ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx}, keys...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoginLockout", reflect.TypeOf((*MockStorage)(nil).GetLoginLockout), varargs...)
+}
+
+// GetOrderByNumber mocks base method.
+func (m *MockStorage) GetOrderByNumber(ctx context.Context, orderNumber string) (*models.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderByNumber", ctx, orderNumber)
+	ret0, _ := ret[0].(*models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderByNumber indicates an expected call of GetOrderByNumber.
+func (mr *MockStorageMockRecorder) GetOrderByNumber(ctx, orderNumber interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderByNumber", reflect.TypeOf((*MockStorage)(nil).GetOrderByNumber), ctx, orderNumber)
+}
+
 // GetOrdersByUser mocks base method.
 func (m *MockStorage) GetOrdersByUser(ctx context.Context) ([]models.Order, error) {
 	m.ctrl.T.Helper()
@@ -79,6 +288,81 @@ func (mr *MockStorageMockRecorder) GetOrdersByUser(ctx interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersByUser", reflect.TypeOf((*MockStorage)(nil).GetOrdersByUser), ctx)
 }
 
+// GetOrdersByUserID mocks base method.
+func (m *MockStorage) GetOrdersByUserID(ctx context.Context, userID int) ([]models.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrdersByUserID", ctx, userID)
+	ret0, _ := ret[0].([]models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrdersByUserID indicates an expected call of GetOrdersByUserID.
+func (mr *MockStorageMockRecorder) GetOrdersByUserID(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersByUserID", reflect.TypeOf((*MockStorage)(nil).GetOrdersByUserID), ctx, userID)
+}
+
+// GetPasswordResetUser mocks base method.
+func (m *MockStorage) GetPasswordResetUser(ctx context.Context, tokenHash string) (*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPasswordResetUser", ctx, tokenHash)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPasswordResetUser indicates an expected call of GetPasswordResetUser.
+func (mr *MockStorageMockRecorder) GetPasswordResetUser(ctx, tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPasswordResetUser", reflect.TypeOf((*MockStorage)(nil).GetPasswordResetUser), ctx, tokenHash)
+}
+
+// GetPendingOrders mocks base method.
+func (m *MockStorage) GetPendingOrders(ctx context.Context, limit int) ([]models.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPendingOrders", ctx, limit)
+	ret0, _ := ret[0].([]models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPendingOrders indicates an expected call of GetPendingOrders.
+func (mr *MockStorageMockRecorder) GetPendingOrders(ctx, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPendingOrders", reflect.TypeOf((*MockStorage)(nil).GetPendingOrders), ctx, limit)
+}
+
+// GetTOTP mocks base method.
+func (m *MockStorage) GetTOTP(ctx context.Context, userID int) (*models.TOTP, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTOTP", ctx, userID)
+	ret0, _ := ret[0].(*models.TOTP)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTOTP indicates an expected call of GetTOTP.
+func (mr *MockStorageMockRecorder) GetTOTP(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTOTP", reflect.TypeOf((*MockStorage)(nil).GetTOTP), ctx, userID)
+}
+
+// GetUserByID mocks base method.
+func (m *MockStorage) GetUserByID(ctx context.Context, userID int) (*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByID", ctx, userID)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByID indicates an expected call of GetUserByID.
+func (mr *MockStorageMockRecorder) GetUserByID(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockStorage)(nil).GetUserByID), ctx, userID)
+}
+
 // GetUserByLogin mocks base method.
 func (m *MockStorage) GetUserByLogin(ctx context.Context, login string) (*models.User, error) {
 	m.ctrl.T.Helper()
@@ -109,6 +393,107 @@ func (mr *MockStorageMockRecorder) GetUserWithdrawals(ctx interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserWithdrawals", reflect.TypeOf((*MockStorage)(nil).GetUserWithdrawals), ctx)
 }
 
+// IsSessionRevoked mocks base method.
+func (m *MockStorage) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsSessionRevoked", ctx, sessionID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsSessionRevoked indicates an expected call of IsSessionRevoked.
+func (mr *MockStorageMockRecorder) IsSessionRevoked(ctx, sessionID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsSessionRevoked", reflect.TypeOf((*MockStorage)(nil).IsSessionRevoked), ctx, sessionID)
+}
+
+// LockLogin mocks base method.
+func (m *MockStorage) LockLogin(ctx context.Context, key string, d time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockLogin", ctx, key, d)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LockLogin indicates an expected call of LockLogin.
+func (mr *MockStorageMockRecorder) LockLogin(ctx, key, d interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockLogin", reflect.TypeOf((*MockStorage)(nil).LockLogin), ctx, key, d)
+}
+
+// RecordLoginFailure mocks base method.
+func (m *MockStorage) RecordLoginFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordLoginFailure", ctx, key, window)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordLoginFailure indicates an expected call of RecordLoginFailure.
+func (mr *MockStorageMockRecorder) RecordLoginFailure(ctx, key, window interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordLoginFailure", reflect.TypeOf((*MockStorage)(nil).RecordLoginFailure), ctx, key, window)
+}
+
+// ResetLoginFailures mocks base method.
+func (m *MockStorage) ResetLoginFailures(ctx context.Context, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetLoginFailures", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetLoginFailures indicates an expected call of ResetLoginFailures.
+func (mr *MockStorageMockRecorder) ResetLoginFailures(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetLoginFailures", reflect.TypeOf((*MockStorage)(nil).ResetLoginFailures), ctx, key)
+}
+
+// ResetPassword mocks base method.
+func (m *MockStorage) ResetPassword(ctx context.Context, tokenHash, hashedPassword string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPassword", ctx, tokenHash, hashedPassword)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetPassword indicates an expected call of ResetPassword.
+func (mr *MockStorageMockRecorder) ResetPassword(ctx, tokenHash, hashedPassword interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPassword", reflect.TypeOf((*MockStorage)(nil).ResetPassword), ctx, tokenHash, hashedPassword)
+}
+
+// RevokeSession mocks base method.
+func (m *MockStorage) RevokeSession(ctx context.Context, sessionID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeSession", ctx, sessionID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeSession indicates an expected call of RevokeSession.
+func (mr *MockStorageMockRecorder) RevokeSession(ctx, sessionID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeSession", reflect.TypeOf((*MockStorage)(nil).RevokeSession), ctx, sessionID)
+}
+
+// RotateRefreshToken mocks base method.
+func (m *MockStorage) RotateRefreshToken(ctx context.Context, refreshTokenHash, newRefreshTokenHash string, expiresAt time.Time) (*models.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RotateRefreshToken", ctx, refreshTokenHash, newRefreshTokenHash, expiresAt)
+	ret0, _ := ret[0].(*models.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RotateRefreshToken indicates an expected call of RotateRefreshToken.
+func (mr *MockStorageMockRecorder) RotateRefreshToken(ctx, refreshTokenHash, newRefreshTokenHash, expiresAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RotateRefreshToken", reflect.TypeOf((*MockStorage)(nil).RotateRefreshToken), ctx, refreshTokenHash, newRefreshTokenHash, expiresAt)
+}
+
 // SaveOrder mocks base method.
 func (m *MockStorage) SaveOrder(ctx context.Context, orderNumber string) (bool, error) {
 	m.ctrl.T.Helper()
@@ -124,8 +509,51 @@ func (mr *MockStorageMockRecorder) SaveOrder(ctx, orderNumber interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveOrder", reflect.TypeOf((*MockStorage)(nil).SaveOrder), ctx, orderNumber)
 }
 
+// ScheduleOrderPoll mocks base method.
+func (m *MockStorage) ScheduleOrderPoll(ctx context.Context, orderNumber string, delay time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScheduleOrderPoll", ctx, orderNumber, delay)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ScheduleOrderPoll indicates an expected call of ScheduleOrderPoll.
+func (mr *MockStorageMockRecorder) ScheduleOrderPoll(ctx, orderNumber, delay interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScheduleOrderPoll", reflect.TypeOf((*MockStorage)(nil).ScheduleOrderPoll), ctx, orderNumber, delay)
+}
+
+// SetTOTPSecret mocks base method.
+func (m *MockStorage) SetTOTPSecret(ctx context.Context, userID int, secret string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTOTPSecret", ctx, userID, secret)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetTOTPSecret indicates an expected call of SetTOTPSecret.
+func (mr *MockStorageMockRecorder) SetTOTPSecret(ctx, userID, secret interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTOTPSecret", reflect.TypeOf((*MockStorage)(nil).SetTOTPSecret), ctx, userID, secret)
+}
+
+// SettleOrder mocks base method.
+func (m *MockStorage) SettleOrder(ctx context.Context, orderNumber, status string, accrual money.Amount) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SettleOrder", ctx, orderNumber, status, accrual)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SettleOrder indicates an expected call of SettleOrder.
+func (mr *MockStorageMockRecorder) SettleOrder(ctx, orderNumber, status, accrual interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SettleOrder", reflect.TypeOf((*MockStorage)(nil).SettleOrder), ctx, orderNumber, status, accrual)
+}
+
 // UpdateOrderStatus mocks base method.
-func (m *MockStorage) UpdateOrderStatus(ctx context.Context, orderNumber, status string, accrual float64) error {
+func (m *MockStorage) UpdateOrderStatus(ctx context.Context, orderNumber, status string, accrual money.Amount) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "UpdateOrderStatus", ctx, orderNumber, status, accrual)
 	ret0, _ := ret[0].(error)
@@ -138,30 +566,59 @@ func (mr *MockStorageMockRecorder) UpdateOrderStatus(ctx, orderNumber, status, a
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrderStatus", reflect.TypeOf((*MockStorage)(nil).UpdateOrderStatus), ctx, orderNumber, status, accrual)
 }
 
-// UpdateUserBalance mocks base method.
-func (m *MockStorage) UpdateUserBalance(ctx context.Context, sum float64) error {
+// UpdatePassword mocks base method.
+func (m *MockStorage) UpdatePassword(ctx context.Context, userID int, hashedPassword string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateUserBalance", ctx, sum)
+	ret := m.ctrl.Call(m, "UpdatePassword", ctx, userID, hashedPassword)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// UpdateUserBalance indicates an expected call of UpdateUserBalance.
-func (mr *MockStorageMockRecorder) UpdateUserBalance(ctx, sum interface{}) *gomock.Call {
+// UpdatePassword indicates an expected call of UpdatePassword.
+func (mr *MockStorageMockRecorder) UpdatePassword(ctx, userID, hashedPassword interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserBalance", reflect.TypeOf((*MockStorage)(nil).UpdateUserBalance), ctx, sum)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePassword", reflect.TypeOf((*MockStorage)(nil).UpdatePassword), ctx, userID, hashedPassword)
 }
 
-// WithdrawUserBalance mocks base method.
-func (m *MockStorage) WithdrawUserBalance(ctx context.Context, orderNumber string, amount float64) error {
+// UseRecoveryCode mocks base method.
+func (m *MockStorage) UseRecoveryCode(ctx context.Context, userID int, codeHash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", ctx, userID, codeHash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockStorageMockRecorder) UseRecoveryCode(ctx, userID, codeHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockStorage)(nil).UseRecoveryCode), ctx, userID, codeHash)
+}
+
+// UseTOTPStep mocks base method.
+func (m *MockStorage) UseTOTPStep(ctx context.Context, userID int, step int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "WithdrawUserBalance", ctx, orderNumber, amount)
+	ret := m.ctrl.Call(m, "UseTOTPStep", ctx, userID, step)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// UseTOTPStep indicates an expected call of UseTOTPStep.
+func (mr *MockStorageMockRecorder) UseTOTPStep(ctx, userID, step interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseTOTPStep", reflect.TypeOf((*MockStorage)(nil).UseTOTPStep), ctx, userID, step)
+}
+
+// WithdrawUserBalance mocks base method.
+func (m *MockStorage) WithdrawUserBalance(ctx context.Context, orderNumber string, amount money.Amount, idempotencyKey string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithdrawUserBalance", ctx, orderNumber, amount, idempotencyKey)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // WithdrawUserBalance indicates an expected call of WithdrawUserBalance.
-func (mr *MockStorageMockRecorder) WithdrawUserBalance(ctx, orderNumber, amount interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) WithdrawUserBalance(ctx, orderNumber, amount, idempotencyKey interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithdrawUserBalance", reflect.TypeOf((*MockStorage)(nil).WithdrawUserBalance), ctx, orderNumber, amount)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithdrawUserBalance", reflect.TypeOf((*MockStorage)(nil).WithdrawUserBalance), ctx, orderNumber, amount, idempotencyKey)
 }
@@ -0,0 +1,113 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/money"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage"
+)
+
+// Ссылка проводки, которой списывается остаток удалённой учётной записи
+const forfeitReference = "account_deleted"
+
+// Удаляет учётную запись. Строка пользователя остаётся без персональных данных: проводки, заказы
+// и списания сохраняются, поэтому номера заказов нельзя использовать повторно. Остаток баланса
+// фиксируется в deleted_accounts; при политике forfeit он переводится на счёт сгоревших баллов,
+// при archive остаётся на счёте пользователя
+func (s *StorageDB) DeleteUser(ctx context.Context, userID int, balancePolicy string) error {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var login string
+	err = tx.QueryRowContext(ctx, `
+		SELECT login FROM users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE
+	`, userID).Scan(&login)
+	if err == sql.ErrNoRows {
+		return storage.ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	// Блокировка строки баланса не даёт параллельным списаниям и корректировкам изменить остаток
+	_, err = tx.ExecContext(ctx, `SELECT 1 FROM balance WHERE user_id = $1 FOR UPDATE`, userID)
+	if err != nil {
+		return err
+	}
+
+	var current, withdrawn money.Amount
+	err = tx.QueryRowContext(ctx, `
+		SELECT
+			COALESCE(SUM(amount) FILTER (WHERE account = $2), 0),
+			COALESCE(SUM(amount) FILTER (WHERE account = $3), 0)
+		FROM ledger
+		WHERE user_id = $1
+	`, userID, accountCurrent, accountWithdrawn).Scan(&current, &withdrawn)
+	if err != nil {
+		return err
+	}
+
+	if balancePolicy == models.BalancePolicyForfeit && current.IsPositive() {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE balance SET current = current - $2 WHERE user_id = $1
+		`, userID, current)
+		if err != nil {
+			return err
+		}
+
+		err = post(ctx, tx, userID, models.PostingForfeit, forfeitReference,
+			posting{account: accountCurrent, amount: current.Neg()},
+			posting{account: accountForfeited, amount: current},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO deleted_accounts (user_id, balance_policy, current, withdrawn, deleted_at)
+		VALUES ($1, $2, $3, $4, NOW())
+	`, userID, balancePolicy, current, withdrawn)
+	if err != nil {
+		return err
+	}
+
+	// Логин освобождается для новой регистрации, войти в учётную запись больше нельзя
+	_, err = tx.ExecContext(ctx, `
+		UPDATE users
+		SET login = NULL, password = '', totp_secret = NULL, totp_enabled = FALSE, totp_last_step = NULL,
+			deleted_at = NOW(), updated_at = NOW()
+		WHERE id = $1
+	`, userID)
+	if err != nil {
+		return err
+	}
+
+	// Данные входа удаляются, сессии — вместе с цепочками refresh-токенов
+	for _, query := range []string{
+		`DELETE FROM sessions WHERE user_id = $1`,
+		`DELETE FROM password_reset_tokens WHERE user_id = $1`,
+		`DELETE FROM recovery_codes WHERE user_id = $1`,
+		`DELETE FROM two_factor_challenges WHERE user_id = $1`,
+	} {
+		if _, err = tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+	}
+
+	loginKey := "login:" + strings.ToLower(login)
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM login_attempts WHERE key IN ($1, $2)
+	`, loginKey, "reset:"+loginKey)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,122 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/money"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Сумма проводок пользователя по счёту
+func ledgerSum(t *testing.T, s *StorageDB, userID int, account string) money.Amount {
+	t.Helper()
+
+	var sum money.Amount
+	err := s.conn.QueryRow(`
+		SELECT COALESCE(SUM(amount), 0) FROM ledger WHERE user_id = $1 AND account = $2
+	`, userID, account).Scan(&sum)
+	require.NoError(t, err)
+	return sum
+}
+
+// Пользователь с начислением 100 и списанием 30 по разным номерам заказов
+func createDeletedTestUser(t *testing.T, s *StorageDB, balancePolicy string) int {
+	t.Helper()
+
+	ctx, userID := createTestUser(t, s, "user")
+	creditTestUser(t, s, ctx, "2377225624", "100")
+	_, err := s.WithdrawUserBalance(ctx, "12345678903", money.MustParse("30"), "")
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteUser(context.Background(), userID, balancePolicy))
+
+	return userID
+}
+
+// Тестирование удаления с политикой forfeit: остаток переводится на счёт сгоревших баллов
+func TestStorageDB_DeleteUser_Forfeit(t *testing.T) {
+	s := newTestStorage(t)
+	userID := createDeletedTestUser(t, s, models.BalancePolicyForfeit)
+
+	// Проводки сохраняются, остаток списан проводкой FORFEIT
+	assert.True(t, ledgerSum(t, s, userID, accountCurrent).IsZero())
+	assert.Equal(t, money.MustParse("30"), ledgerSum(t, s, userID, accountWithdrawn))
+	assert.Equal(t, money.MustParse("70"), ledgerSum(t, s, userID, accountForfeited))
+
+	var kind string
+	err := s.conn.QueryRow(`
+		SELECT kind FROM ledger WHERE user_id = $1 AND account = $2
+	`, userID, accountForfeited).Scan(&kind)
+	require.NoError(t, err)
+	assert.Equal(t, models.PostingForfeit, kind)
+
+	var policy string
+	var current money.Amount
+	err = s.conn.QueryRow(`
+		SELECT balance_policy, current FROM deleted_accounts WHERE user_id = $1
+	`, userID).Scan(&policy, &current)
+	require.NoError(t, err)
+	assert.Equal(t, models.BalancePolicyForfeit, policy)
+	assert.Equal(t, money.MustParse("70"), current)
+	assertLedgerReconciled(t, s)
+}
+
+// Тестирование удаления с политикой archive: остаток остаётся на счёте пользователя
+func TestStorageDB_DeleteUser_Archive(t *testing.T) {
+	s := newTestStorage(t)
+	userID := createDeletedTestUser(t, s, models.BalancePolicyArchive)
+
+	assert.Equal(t, money.MustParse("70"), ledgerSum(t, s, userID, accountCurrent))
+	assert.Equal(t, money.MustParse("30"), ledgerSum(t, s, userID, accountWithdrawn))
+	assert.True(t, ledgerSum(t, s, userID, accountForfeited).IsZero())
+
+	var policy string
+	err := s.conn.QueryRow(`SELECT balance_policy FROM deleted_accounts WHERE user_id = $1`, userID).Scan(&policy)
+	require.NoError(t, err)
+	assert.Equal(t, models.BalancePolicyArchive, policy)
+	assertLedgerReconciled(t, s)
+}
+
+// Тестирование удалённой учётной записи: персональные данные стёрты, номера заказов остаются занятыми
+func TestStorageDB_DeleteUser_Tombstone(t *testing.T) {
+	s := newTestStorage(t)
+	userID := createDeletedTestUser(t, s, models.BalancePolicyForfeit)
+
+	_, err := s.GetUserByID(context.Background(), userID)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+	assert.ErrorIs(t, s.DeleteUser(context.Background(), userID, models.BalancePolicyForfeit), storage.ErrUserNotFound)
+
+	// Логин освобождается для новой регистрации
+	ctx, _ := createTestUser(t, s, "user")
+	creditTestUser(t, s, ctx, "79927398713", "100")
+
+	// Номер загруженного заказа и номер списания удалённого пользователя использовать нельзя
+	_, err = s.SaveOrder(ctx, "2377225624")
+	assert.ErrorIs(t, err, storage.ErrOrderExistsForAnother)
+	_, err = s.WithdrawUserBalance(ctx, "12345678903", money.MustParse("10"), "")
+	assert.ErrorIs(t, err, storage.ErrOrderAlreadyWithdrawn)
+
+	// Строку пользователя нельзя удалить, пока на неё ссылаются проводки
+	_, err = s.conn.Exec(`DELETE FROM users WHERE id = $1`, userID)
+	var pgErr *pgconn.PgError
+	require.True(t, errors.As(err, &pgErr))
+	assert.Equal(t, "23503", pgErr.Code)
+}
+
+// Тестирование того, что заказы удалённых учётных записей не обрабатываются
+func TestStorageDB_GetPendingOrders_DeletedUser(t *testing.T) {
+	s := newTestStorage(t)
+	ctx, userID := createTestUser(t, s, "user")
+	_, err := s.SaveOrder(ctx, "2377225624")
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteUser(context.Background(), userID, models.BalancePolicyArchive))
+
+	orders, err := s.GetPendingOrders(context.Background(), 10)
+	require.NoError(t, err)
+	assert.Empty(t, orders)
+}
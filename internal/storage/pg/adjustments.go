@@ -0,0 +1,134 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Ручная корректировка баланса: запись в журнал, обновление таблицы balance и проводка
+// по контрсчёту корректировок. Списание не может сделать баланс отрицательным.
+// Повтор с тем же ключом идемпотентности возвращает исходную корректировку
+func (s *StorageDB) AdjustBalance(ctx context.Context, adjustment models.BalanceAdjustment) (*models.BalanceAdjustment, error) {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Блокировка строки пользователя не даёт удалить учётную запись до конца корректировки
+	var exists bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND deleted_at IS NULL FOR SHARE)
+	`, adjustment.UserID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, storage.ErrUserNotFound
+	}
+
+	// Остаток по главной книге под блокировкой строки баланса, как при списании
+	currentBalance, _, err := lockCurrentBalance(ctx, tx, adjustment.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Поиск ранее выполненной корректировки с тем же ключом
+	if adjustment.IdempotencyKey != "" {
+		var existing models.BalanceAdjustment
+		err = tx.QueryRowContext(ctx, `
+			SELECT id, user_id, operator_id, amount, reason, idempotency_key, created_at
+			FROM balance_adjustments
+			WHERE operator_id = $1 AND idempotency_key = $2
+		`, adjustment.OperatorID, adjustment.IdempotencyKey).Scan(&existing.ID, &existing.UserID, &existing.OperatorID,
+			&existing.Amount, &existing.Reason, &existing.IdempotencyKey, &existing.CreatedAt)
+		if err == nil {
+			if existing.UserID == adjustment.UserID && existing.Amount == adjustment.Amount && existing.Reason == adjustment.Reason {
+				return &existing, nil
+			}
+			return nil, storage.ErrIdempotencyKeyReused
+		}
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+
+	if adjustment.Amount.IsNegative() && currentBalance.Cmp(adjustment.Amount.Neg()) < 0 {
+		return nil, storage.ErrInsufficientFunds
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO balance (user_id, current, withdrawn)
+		VALUES ($1, $2, 0)
+		ON CONFLICT (user_id) DO UPDATE
+		SET current = balance.current + EXCLUDED.current
+	`, adjustment.UserID, adjustment.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO balance_adjustments (user_id, operator_id, amount, reason, idempotency_key, created_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NOW())
+		RETURNING id, created_at
+	`, adjustment.UserID, adjustment.OperatorID, adjustment.Amount, adjustment.Reason, adjustment.IdempotencyKey).Scan(&adjustment.ID, &adjustment.CreatedAt)
+	if err != nil {
+		// Тот же ключ одновременно использован для корректировки баланса другого пользователя
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "idx_balance_adjustments_idempotency_key" {
+			return nil, fmt.Errorf("%w: %w", storage.ErrIdempotencyKeyReused, err)
+		}
+		return nil, err
+	}
+
+	// Причина корректировки видна пользователю в истории движений
+	err = post(ctx, tx, adjustment.UserID, models.PostingAdjustment, adjustment.Reason,
+		posting{account: accountCurrent, amount: adjustment.Amount},
+		posting{account: accountAdjustment, amount: adjustment.Amount.Neg()},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &adjustment, nil
+}
+
+// Журнал корректировок баланса пользователя, новые записи первыми
+func (s *StorageDB) GetBalanceAdjustments(ctx context.Context, userID int) ([]models.BalanceAdjustment, error) {
+	rows, err := s.conn.QueryContext(ctx, `
+		SELECT id, user_id, operator_id, amount, reason, created_at
+		FROM balance_adjustments
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var adjustments []models.BalanceAdjustment
+	for rows.Next() {
+		var adjustment models.BalanceAdjustment
+		err := rows.Scan(&adjustment.ID, &adjustment.UserID, &adjustment.OperatorID, &adjustment.Amount, &adjustment.Reason, &adjustment.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		adjustments = append(adjustments, adjustment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return adjustments, nil
+}
@@ -0,0 +1,110 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/auth"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Переменная окружения с адресом тестовой БД. Без неё тесты хранилища пропускаются
+const testDatabaseEnv = "TEST_DATABASE_URI"
+
+const testMigrationsURL = "file://../../../db/migrations"
+
+// Подключение к тестовой БД в отдельной схеме, которая удаляется после теста
+func newTestDB(t *testing.T) (*sql.DB, *migrate.Migrate) {
+	t.Helper()
+
+	dsn := os.Getenv(testDatabaseEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDatabaseEnv)
+	}
+
+	admin, err := sql.Open("pgx", dsn)
+	require.NoError(t, err)
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	_, err = admin.Exec(`CREATE SCHEMA ` + schema)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`)
+		admin.Close()
+	})
+
+	config, err := pgx.ParseConfig(dsn)
+	require.NoError(t, err)
+	config.RuntimeParams["search_path"] = schema
+	conn := stdlib.OpenDB(*config)
+	t.Cleanup(func() { conn.Close() })
+
+	driver, err := postgres.WithInstance(conn, &postgres.Config{SchemaName: schema})
+	require.NoError(t, err)
+	m, err := migrate.NewWithDatabaseInstance(testMigrationsURL, "postgres", driver)
+	require.NoError(t, err)
+
+	return conn, m
+}
+
+// Хранилище с применёнными миграциями
+func newTestStorage(t *testing.T) *StorageDB {
+	t.Helper()
+
+	conn, m := newTestDB(t)
+	require.NoError(t, m.Up())
+
+	return NewStorage(conn)
+}
+
+// Создаёт пользователя и возвращает контекст запроса от его имени
+func createTestUser(t *testing.T, s *StorageDB, login string) (context.Context, int) {
+	t.Helper()
+
+	ctx := context.Background()
+	require.NoError(t, s.CreateUser(ctx, login, "hash"))
+	user, err := s.GetUserByLogin(ctx, login)
+	require.NoError(t, err)
+
+	return context.WithValue(ctx, auth.UserIDKey, strconv.Itoa(user.ID)), user.ID
+}
+
+// Сверка: каждая проводка сбалансирована, а таблица balance совпадает с остатками по главной книге
+func assertLedgerReconciled(t *testing.T, s *StorageDB) {
+	t.Helper()
+
+	var unbalanced int
+	err := s.conn.QueryRow(`
+		SELECT COUNT(*) FROM (
+			SELECT transaction_id FROM ledger GROUP BY transaction_id HAVING SUM(amount) <> 0
+		) t
+	`).Scan(&unbalanced)
+	require.NoError(t, err)
+	assert.Zero(t, unbalanced, "unbalanced ledger transactions")
+
+	var mismatched int
+	err = s.conn.QueryRow(`
+		SELECT COUNT(*)
+		FROM balance b
+		LEFT JOIN (
+			SELECT user_id,
+				COALESCE(SUM(amount) FILTER (WHERE account = $1), 0) AS current,
+				COALESCE(SUM(amount) FILTER (WHERE account = $2), 0) AS withdrawn
+			FROM ledger
+			GROUP BY user_id
+		) l ON l.user_id = b.user_id
+		WHERE b.current <> COALESCE(l.current, 0) OR b.withdrawn <> COALESCE(l.withdrawn, 0)
+	`, accountCurrent, accountWithdrawn).Scan(&mismatched)
+	require.NoError(t, err)
+	assert.Zero(t, mismatched, "balance rows differing from the ledger")
+}
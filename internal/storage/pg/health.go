@@ -0,0 +1,32 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+)
+
+// Проверка соединения с БД
+func (s *StorageDB) Ping(ctx context.Context) error {
+	return s.conn.PingContext(ctx)
+}
+
+// Проверяет, что к БД применены все миграции этой версии сервиса. Более новая схема допустима:
+// при поэтапном обновлении её уже мог применить экземпляр следующей версии
+func (s *StorageDB) CheckMigrations(ctx context.Context, expected uint) error {
+	var version uint
+	var dirty bool
+	err := s.conn.QueryRowContext(ctx, `
+		SELECT version, dirty FROM schema_migrations LIMIT 1
+	`).Scan(&version, &dirty)
+	if err != nil {
+		return fmt.Errorf("read migration version: %w", err)
+	}
+
+	if dirty {
+		return fmt.Errorf("migration %d failed and left the schema dirty", version)
+	}
+	if version < expected {
+		return fmt.Errorf("migration version %d, expected at least %d", version, expected)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package pg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тестирование проверки версии схемы: более новая схема допустима, отставшая и незавершённая — нет
+func TestStorageDB_CheckMigrations(t *testing.T) {
+	conn, m := newTestDB(t)
+	require.NoError(t, m.Up())
+	s := NewStorage(conn)
+	ctx := context.Background()
+
+	version, _, err := m.Version()
+	require.NoError(t, err)
+
+	assert.NoError(t, s.CheckMigrations(ctx, version))
+	assert.NoError(t, s.CheckMigrations(ctx, version-1))
+	assert.Error(t, s.CheckMigrations(ctx, version+1))
+
+	_, err = conn.Exec(`UPDATE schema_migrations SET dirty = TRUE`)
+	require.NoError(t, err)
+	assert.Error(t, s.CheckMigrations(ctx, version))
+}
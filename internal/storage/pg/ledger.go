@@ -0,0 +1,154 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/auth"
+	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/money"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage"
+)
+
+// Счета главной книги. Каждый пользователь имеет собственный набор счетов
+const (
+	accountCurrent    = "current"    // доступные баллы
+	accountWithdrawn  = "withdrawn"  // списанные баллы
+	accountAccrual    = "accrual"    // контрсчёт начислений системы лояльности
+	accountAdjustment = "adjustment" // контрсчёт ручных корректировок
+	accountForfeited  = "forfeited"  // сгоревшие баллы удалённой учётной записи
+)
+
+// Строка проводки
+type posting struct {
+	account string
+	amount  money.Amount
+}
+
+// Записывает проводку в главную книгу. Сумма всех строк проводки должна быть равна нулю
+func post(ctx context.Context, tx *sql.Tx, userID int, kind, reference string, entries ...posting) error {
+	var total money.Amount
+	for _, entry := range entries {
+		var err error
+		if total, err = total.Add(entry.amount); err != nil {
+			return err
+		}
+	}
+	if !total.IsZero() {
+		return storage.ErrUnbalancedLedgerPosting
+	}
+
+	var transactionID int64
+	err := tx.QueryRowContext(ctx, `SELECT nextval('ledger_transaction_seq')`).Scan(&transactionID)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO ledger (transaction_id, user_id, account, kind, amount, reference, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		`, transactionID, userID, entry.account, kind, entry.amount, reference)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Блокирует строку баланса пользователя и возвращает доступный остаток по главной книге.
+// Строку balance блокируют все операции, меняющие остаток, поэтому до конца транзакции он
+// не изменится. found = false, если баллы пользователю ещё не начислялись
+func lockCurrentBalance(ctx context.Context, tx *sql.Tx, userID int) (current money.Amount, found bool, err error) {
+	var locked int
+	err = tx.QueryRowContext(ctx, `
+		SELECT 1 FROM balance WHERE user_id = $1 FOR UPDATE
+	`, userID).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return current, false, nil
+	}
+	if err != nil {
+		return current, false, err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(amount), 0) FROM ledger WHERE user_id = $1 AND account = $2
+	`, userID, accountCurrent).Scan(&current)
+	if err != nil {
+		return current, false, err
+	}
+
+	return current, true, nil
+}
+
+// Начисляет баллы: проводка по счетам пользователя и обновление таблицы balance
+func creditBalance(ctx context.Context, tx *sql.Tx, userID int, sum money.Amount, reference string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO balance (user_id, current, withdrawn)
+		VALUES ($1, $2, 0)
+		ON CONFLICT (user_id) DO UPDATE
+		SET current = balance.current + EXCLUDED.current
+	`, userID, sum)
+	if err != nil {
+		return err
+	}
+
+	return post(ctx, tx, userID, models.PostingAccrual, reference,
+		posting{account: accountCurrent, amount: sum},
+		posting{account: accountAccrual, amount: sum.Neg()},
+	)
+}
+
+// История движений по счёту доступных баллов пользователя
+func (s *StorageDB) GetBalanceHistory(ctx context.Context) ([]models.Posting, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.conn.QueryContext(ctx, `
+		SELECT kind, amount, COALESCE(reference, ''), created_at
+		FROM ledger
+		WHERE user_id = $1 AND account = $2
+		ORDER BY created_at DESC, id DESC
+	`, userID, accountCurrent)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []models.Posting
+	for rows.Next() {
+		var item models.Posting
+		var createdAt time.Time
+		err := rows.Scan(&item.Kind, &item.Amount, &item.Reference, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		item.ProcessedAt = createdAt.Format(time.RFC3339)
+		history = append(history, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// Идентификатор пользователя, сохранённый в контексте запроса
+func contextUserID(ctx context.Context) (int, error) {
+	value, ok := ctx.Value(auth.UserIDKey).(string)
+	if !ok {
+		return 0, storage.ErrNoUserInContext
+	}
+	userID, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", storage.ErrNoUserInContext, err)
+	}
+	return userID, nil
+}
@@ -0,0 +1,85 @@
+package pg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/money"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тестирование неизменяемости главной книги: проводки нельзя изменить или удалить
+func TestLedger_AppendOnly(t *testing.T) {
+	s := newTestStorage(t)
+	ctx, _ := createTestUser(t, s, "user")
+	creditTestUser(t, s, ctx, "2377225624", "100")
+
+	for _, query := range []string{
+		`UPDATE ledger SET amount = amount * 2`,
+		`DELETE FROM ledger`,
+		`TRUNCATE ledger`,
+	} {
+		_, err := s.conn.Exec(query)
+		if assert.Error(t, err, query) {
+			assert.Contains(t, err.Error(), "ledger postings are immutable")
+		}
+	}
+
+	balance, err := s.GetBalance(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, money.MustParse("100"), balance.Current)
+}
+
+// Тестирование корректировок: остаток для списания берётся из главной книги
+func TestStorageDB_AdjustBalance(t *testing.T) {
+	s := newTestStorage(t)
+	ctx, userID := createTestUser(t, s, "user")
+	creditTestUser(t, s, ctx, "2377225624", "100")
+	_, operatorID := createTestUser(t, s, "admin")
+
+	_, err := s.AdjustBalance(context.Background(), models.BalanceAdjustment{
+		UserID: userID, OperatorID: operatorID, Amount: money.MustParse("-40"), Reason: "refund",
+	})
+	require.NoError(t, err)
+
+	_, err = s.AdjustBalance(context.Background(), models.BalanceAdjustment{
+		UserID: userID, OperatorID: operatorID, Amount: money.MustParse("-61"), Reason: "refund",
+	})
+	assert.ErrorIs(t, err, storage.ErrInsufficientFunds)
+
+	balance, err := s.GetBalance(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, money.MustParse("60"), balance.Current)
+	assertLedgerReconciled(t, s)
+}
+
+// Тестирование повтора корректировки с тем же ключом идемпотентности
+func TestStorageDB_AdjustBalance_Idempotency(t *testing.T) {
+	s := newTestStorage(t)
+	ctx, userID := createTestUser(t, s, "user")
+	_, operatorID := createTestUser(t, s, "admin")
+
+	adjustment := models.BalanceAdjustment{
+		UserID: userID, OperatorID: operatorID, Amount: money.MustParse("25"), Reason: "bonus", IdempotencyKey: "key-1",
+	}
+	first, err := s.AdjustBalance(context.Background(), adjustment)
+	require.NoError(t, err)
+
+	// Повтор возвращает исходную корректировку и не начисляет баллы повторно
+	again, err := s.AdjustBalance(context.Background(), adjustment)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, again.ID)
+
+	// Тот же ключ с другими параметрами отклоняется
+	adjustment.Amount = money.MustParse("30")
+	_, err = s.AdjustBalance(context.Background(), adjustment)
+	assert.ErrorIs(t, err, storage.ErrIdempotencyKeyReused)
+
+	balance, err := s.GetBalance(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, money.MustParse("25"), balance.Current)
+	assertLedgerReconciled(t, s)
+}
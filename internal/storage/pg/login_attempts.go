@@ -0,0 +1,62 @@
+package pg
+
+import (
+	"context"
+	"time"
+)
+
+// Оставшееся время блокировки входа по любому из ключей.
+// Время отсчитывается часами БД, поэтому одинаково для всех реплик сервиса
+func (s *StorageDB) GetLoginLockout(ctx context.Context, keys ...string) (time.Duration, error) {
+	var seconds float64
+	err := s.conn.QueryRowContext(ctx, `
+		SELECT COALESCE(MAX(EXTRACT(EPOCH FROM locked_until - NOW())), 0)
+		FROM login_attempts
+		WHERE key = ANY($1) AND locked_until > NOW()
+	`, keys).Scan(&seconds)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// Учитывает неудачную попытку входа и возвращает число неудач подряд.
+// Если с предыдущей неудачи прошло больше window, счёт начинается заново
+func (s *StorageDB) RecordLoginFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	var failures int
+	err := s.conn.QueryRowContext(ctx, `
+		INSERT INTO login_attempts (key, failures, last_failure_at)
+		VALUES ($1, 1, NOW())
+		ON CONFLICT (key) DO UPDATE
+		SET failures = CASE
+				WHEN login_attempts.last_failure_at < NOW() - make_interval(secs => $2) THEN 1
+				ELSE login_attempts.failures + 1
+			END,
+			last_failure_at = NOW()
+		RETURNING failures
+	`, key, window.Seconds()).Scan(&failures)
+	if err != nil {
+		return 0, err
+	}
+
+	return failures, nil
+}
+
+// Блокирует вход по ключу на заданное время
+func (s *StorageDB) LockLogin(ctx context.Context, key string, d time.Duration) error {
+	_, err := s.conn.ExecContext(ctx, `
+		UPDATE login_attempts
+		SET locked_until = NOW() + make_interval(secs => $2)
+		WHERE key = $1
+	`, key, d.Seconds())
+	return err
+}
+
+// Сбрасывает счётчик неудачных попыток после успешного входа
+func (s *StorageDB) ResetLoginFailures(ctx context.Context, key string) error {
+	_, err := s.conn.ExecContext(ctx, `
+		DELETE FROM login_attempts WHERE key = $1
+	`, key)
+	return err
+}
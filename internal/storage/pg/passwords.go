@@ -0,0 +1,149 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage"
+)
+
+// Возвращает пользователя по идентификатору. Удалённая учётная запись не находится
+func (s *StorageDB) GetUserByID(ctx context.Context, userID int) (*models.User, error) {
+	row := s.conn.QueryRowContext(ctx, `
+		SELECT id, login, password, role, totp_enabled
+		FROM users
+		WHERE id = $1 AND deleted_at IS NULL
+	`, userID)
+
+	var user models.User
+	err := row.Scan(&user.ID, &user.Login, &user.Password, &user.Role, &user.TOTPEnabled)
+	if err == sql.ErrNoRows {
+		return nil, storage.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// Меняет пароль пользователя и отзывает все его сессии
+func (s *StorageDB) UpdatePassword(ctx context.Context, userID int, hashedPassword string) error {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = setPassword(ctx, tx, userID, hashedPassword); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Сохраняет токен сброса пароля. Ранее выданные неиспользованные токены пользователя
+// становятся недействительными, так что действует только последний отправленный
+func (s *StorageDB) CreatePasswordResetToken(ctx context.Context, userID int, tokenHash string, ttl time.Duration) error {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE password_reset_tokens SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL
+	`, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO password_reset_tokens (token_hash, user_id, created_at, expires_at)
+		VALUES ($1, $2, NOW(), NOW() + make_interval(secs => $3))
+	`, tokenHash, userID, ttl.Seconds())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Возвращает владельца действующего токена сброса пароля, не погашая токен
+func (s *StorageDB) GetPasswordResetUser(ctx context.Context, tokenHash string) (*models.User, error) {
+	row := s.conn.QueryRowContext(ctx, `
+		SELECT u.id, u.login, u.password, u.role, u.totp_enabled
+		FROM password_reset_tokens t
+		JOIN users u ON u.id = t.user_id
+		WHERE t.token_hash = $1 AND t.used_at IS NULL AND t.expires_at > NOW()
+	`, tokenHash)
+
+	var user models.User
+	err := row.Scan(&user.ID, &user.Login, &user.Password, &user.Role, &user.TOTPEnabled)
+	if err == sql.ErrNoRows {
+		return nil, storage.ErrResetTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// Устанавливает новый пароль по токену сброса. Токен погашается,
+// остальные токены пользователя и все его сессии становятся недействительными
+func (s *StorageDB) ResetPassword(ctx context.Context, tokenHash, hashedPassword string) error {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Блокировка строки не даёт использовать токен дважды параллельными запросами
+	var userID int
+	var valid bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT user_id, used_at IS NULL AND expires_at > NOW()
+		FROM password_reset_tokens
+		WHERE token_hash = $1
+		FOR UPDATE
+	`, tokenHash).Scan(&userID, &valid)
+	if err == sql.ErrNoRows || (err == nil && !valid) {
+		return storage.ErrResetTokenInvalid
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = setPassword(ctx, tx, userID, hashedPassword); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Смена пароля внутри транзакции: все сессии и неиспользованные токены сброса пользователя отзываются
+func setPassword(ctx context.Context, tx *sql.Tx, userID int, hashedPassword string) error {
+	result, err := tx.ExecContext(ctx, `
+		UPDATE users SET password = $2, updated_at = NOW() WHERE id = $1
+	`, userID, hashedPassword)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rows == 0 {
+		return storage.ErrUserNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE password_reset_tokens SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL
+	`, userID)
+	if err != nil {
+		return err
+	}
+
+	return revokeUserSessions(ctx, tx, userID)
+}
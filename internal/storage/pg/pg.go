@@ -7,8 +7,9 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/dsemenov12/loyalty-gofermart/internal/auth"
 	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/money"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage"
 	"github.com/jackc/pgx/v5/pgconn"
 )
 
@@ -29,9 +30,10 @@ func (s *StorageDB) CreateUser(ctx context.Context, login, hashedPassword string
 
 	if err != nil {
 		// Проверка ошибки на наличие уникального ограничения
-		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23505" {
-            return errors.New("user already exists")
-        }
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return fmt.Errorf("%w: %w", storage.ErrUserExists, err)
+		}
 		return err
 	}
 	return nil
@@ -40,15 +42,15 @@ func (s *StorageDB) CreateUser(ctx context.Context, login, hashedPassword string
 // Возвращает пользователя по логину
 func (s *StorageDB) GetUserByLogin(ctx context.Context, login string) (*models.User, error) {
 	row := s.conn.QueryRowContext(ctx, `
-		SELECT id, login, password
+		SELECT id, login, password, role, totp_enabled
 		FROM users
 		WHERE login = $1
 	`, login)
 
 	var user models.User
-	err := row.Scan(&user.ID, &user.Login, &user.Password)
+	err := row.Scan(&user.ID, &user.Login, &user.Password, &user.Role, &user.TOTPEnabled)
 	if err == sql.ErrNoRows {
-		return nil, errors.New("user not found")
+		return nil, storage.ErrUserNotFound
 	}
 	if err != nil {
 		return nil, err
@@ -59,19 +61,22 @@ func (s *StorageDB) GetUserByLogin(ctx context.Context, login string) (*models.U
 
 // Сохранение заказа
 func (s *StorageDB) SaveOrder(ctx context.Context, orderNumber string) (bool, error) {
-	userID := ctx.Value(auth.UserIDKey)
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return false, err
+	}
 
 	// Проверка существующего номера заказа
-	var existingUserID string
-	err := s.conn.QueryRowContext(ctx, `
+	var existingUserID int
+	err = s.conn.QueryRowContext(ctx, `
 		SELECT user_id FROM orders WHERE number = $1
 	`, orderNumber).Scan(&existingUserID)
 
 	if err == nil {
 		if existingUserID == userID {
-			return false, errors.New("order already exists for the same user")
+			return false, storage.ErrOrderExistsForUser
 		}
-		return false, errors.New("order already exists for another user")
+		return false, storage.ErrOrderExistsForAnother
 	}
 
 	if err != sql.ErrNoRows {
@@ -81,7 +86,7 @@ func (s *StorageDB) SaveOrder(ctx context.Context, orderNumber string) (bool, er
 	// Вставка нового заказа
 	_, err = s.conn.ExecContext(ctx, `
 		INSERT INTO orders (user_id, number, status, created_at)
-		VALUES ($1, $2, 'PROCESSING', NOW())
+		VALUES ($1, $2, 'NEW', NOW())
 	`, userID, orderNumber)
 
 	if err != nil {
@@ -93,8 +98,16 @@ func (s *StorageDB) SaveOrder(ctx context.Context, orderNumber string) (bool, er
 
 // Список заказов пользователя
 func (s *StorageDB) GetOrdersByUser(ctx context.Context) ([]models.Order, error) {
-	userID := ctx.Value(auth.UserIDKey)
-	
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetOrdersByUserID(ctx, userID)
+}
+
+// Возвращает заказы указанного пользователя
+func (s *StorageDB) GetOrdersByUserID(ctx context.Context, userID int) ([]models.Order, error) {
 	rows, err := s.conn.QueryContext(ctx, `
 		SELECT number, status, accrual, created_at
 		FROM orders
@@ -108,7 +121,7 @@ func (s *StorageDB) GetOrdersByUser(ctx context.Context) ([]models.Order, error)
 
 	var orders []models.Order
 	for rows.Next() {
-		var order models.Order
+		order := models.Order{UserID: userID}
 		err := rows.Scan(&order.Number, &order.Status, &order.Accrual, &order.UploadedAt)
 		if err != nil {
 			return nil, err
@@ -123,56 +136,142 @@ func (s *StorageDB) GetOrdersByUser(ctx context.Context) ([]models.Order, error)
 	return orders, nil
 }
 
-// Получение баланса пользователя
-func (s *StorageDB) GetBalance(ctx context.Context) (*models.Balance, error) {
-	var balance models.Balance
-	userID := ctx.Value(auth.UserIDKey)
+// Не более limit заказов в неокончательных статусах, срок опроса которых наступил, начиная
+// с давно ожидающих. Заказы удалённых учётных записей не обрабатываются: начислять баллы больше некому
+func (s *StorageDB) GetPendingOrders(ctx context.Context, limit int) ([]models.Order, error) {
+	rows, err := s.conn.QueryContext(ctx, `
+		SELECT o.user_id, o.number, o.status, o.created_at, o.poll_attempts
+		FROM orders o
+		JOIN users u ON u.id = o.user_id
+		WHERE o.status IN ('NEW', 'REGISTERED', 'PROCESSING') AND u.deleted_at IS NULL
+			AND o.next_poll_at <= NOW()
+		ORDER BY o.next_poll_at, o.id
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	err := s.conn.QueryRowContext(ctx, `
-		SELECT current, withdrawn
-		FROM balance
-		WHERE user_id = $1
-	`, userID).Scan(&balance.Current, &balance.Withdrawn)
+	var orders []models.Order
+	for rows.Next() {
+		var order models.Order
+		err := rows.Scan(&order.UserID, &order.Number, &order.Status, &order.UploadedAt, &order.PollAttempts)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
 
-	
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// Откладывает следующий опрос заказа на delay и увеличивает счётчик безрезультатных опросов
+func (s *StorageDB) ScheduleOrderPoll(ctx context.Context, orderNumber string, delay time.Duration) error {
+	_, err := s.conn.ExecContext(ctx, `
+		UPDATE orders
+		SET poll_attempts = poll_attempts + 1, next_poll_at = NOW() + make_interval(secs => $2)
+		WHERE number = $1 AND status IN ('NEW', 'REGISTERED', 'PROCESSING')
+	`, orderNumber, delay.Seconds())
+	return err
+}
+
+// Заказ по номеру независимо от того, кто его загрузил
+func (s *StorageDB) GetOrderByNumber(ctx context.Context, orderNumber string) (*models.Order, error) {
+	var order models.Order
+	err := s.conn.QueryRowContext(ctx, `
+		SELECT user_id, number, status, accrual, created_at
+		FROM orders
+		WHERE number = $1
+	`, orderNumber).Scan(&order.UserID, &order.Number, &order.Status, &order.Accrual, &order.UploadedAt)
 	if err == sql.ErrNoRows {
-		balance.Current = 0
-		balance.Withdrawn = 0
+		return nil, storage.ErrOrderNotFound
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &order, nil
+}
+
+// Получение баланса пользователя. Баланс рассчитывается по проводкам главной книги
+func (s *StorageDB) GetBalance(ctx context.Context) (*models.Balance, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetBalanceByUserID(ctx, userID)
+}
 
-	if err != nil && err != sql.ErrNoRows {
+// Возвращает баланс указанного пользователя
+func (s *StorageDB) GetBalanceByUserID(ctx context.Context, userID int) (*models.Balance, error) {
+	var balance models.Balance
+	err := s.conn.QueryRowContext(ctx, `
+		SELECT
+			COALESCE(SUM(amount) FILTER (WHERE account = $2), 0),
+			COALESCE(SUM(amount) FILTER (WHERE account = $3), 0)
+		FROM ledger
+		WHERE user_id = $1
+	`, userID, accountCurrent, accountWithdrawn).Scan(&balance.Current, &balance.Withdrawn)
+	if err != nil {
 		return nil, err
 	}
 
 	return &balance, nil
 }
 
-// Списание средств
-func (s *StorageDB) WithdrawUserBalance(ctx context.Context, orderNumber string, amount float64) error {
-	userID := ctx.Value(auth.UserIDKey)
+// Списание средств. Строка баланса блокируется до конца транзакции, поэтому
+// параллельные списания проверяют остаток по главной книге по очереди. Повтор запроса с тем же
+// ключом идемпотентности не создаёт нового списания. Возвращает true, если баллы списаны этим вызовом
+func (s *StorageDB) WithdrawUserBalance(ctx context.Context, orderNumber string, amount money.Amount, idempotencyKey string) (bool, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return false, err
+	}
 
 	tx, err := s.conn.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer tx.Rollback()
 
-	// Проверка текущего баланса
-	var currentBalance float64
-	err = tx.QueryRowContext(ctx, `
-		SELECT current
-		FROM balance
-		WHERE user_id = $1
-	`, userID).Scan(&currentBalance)
-	if err == sql.ErrNoRows {
-		return errors.New("balance not found")
-	}
+	// Остаток по главной книге под блокировкой строки баланса
+	currentBalance, found, err := lockCurrentBalance(ctx, tx, userID)
 	if err != nil {
-		return err
+		return false, err
+	}
+	if !found {
+		// Баллы ещё ни разу не начислялись
+		return false, storage.ErrInsufficientFunds
+	}
+
+	// Поиск ранее выполненного списания с тем же ключом
+	if idempotencyKey != "" {
+		var existingOrder string
+		var existingSum money.Amount
+		err = tx.QueryRowContext(ctx, `
+			SELECT order_number, sum
+			FROM withdraw
+			WHERE user_id = $1 AND idempotency_key = $2
+		`, userID, idempotencyKey).Scan(&existingOrder, &existingSum)
+		if err == nil {
+			if existingOrder == orderNumber && existingSum == amount {
+				return false, nil
+			}
+			return false, storage.ErrIdempotencyKeyReused
+		}
+		if err != sql.ErrNoRows {
+			return false, err
+		}
 	}
 
-	if currentBalance < amount {
-		return errors.New("insufficient funds")
+	if currentBalance.Cmp(amount) < 0 {
+		return false, storage.ErrInsufficientFunds
 	}
 
 	// Обновление баланса
@@ -182,24 +281,45 @@ func (s *StorageDB) WithdrawUserBalance(ctx context.Context, orderNumber string,
 		WHERE user_id = $1
 	`, userID, amount)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	// Добавление записи в таблицу withdraw
 	_, err = tx.ExecContext(ctx, `
-		INSERT INTO withdraw (user_id, order_number, sum, created_at)
-		VALUES ($1, $2, $3, NOW())
-	`, userID, orderNumber, amount)
+		INSERT INTO withdraw (user_id, order_number, sum, idempotency_key, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NOW())
+	`, userID, orderNumber, amount, idempotencyKey)
 	if err != nil {
-		return err
+		// Номер заказа уже использовался для списания
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "idx_withdraw_order_number" {
+			return false, fmt.Errorf("%w: %w", storage.ErrOrderAlreadyWithdrawn, err)
+		}
+		return false, err
 	}
 
-	return tx.Commit()
+	// Проводка списания в главной книге
+	err = post(ctx, tx, userID, models.PostingWithdrawal, orderNumber,
+		posting{account: accountCurrent, amount: amount.Neg()},
+		posting{account: accountWithdrawn, amount: amount},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+
+	return true, nil
 }
 
 // Получение списка списаний
 func (s *StorageDB) GetUserWithdrawals(ctx context.Context) ([]models.Withdrawal, error) {
-	userID := ctx.Value(auth.UserIDKey)
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	rows, err := s.conn.QueryContext(ctx, `
 		SELECT order_number, sum, created_at
@@ -232,7 +352,7 @@ func (s *StorageDB) GetUserWithdrawals(ctx context.Context) ([]models.Withdrawal
 }
 
 // Обновляет статус заказа и количество начисленных баллов
-func (s *StorageDB) UpdateOrderStatus(ctx context.Context, orderNumber, status string, accrual float64) error {
+func (s *StorageDB) UpdateOrderStatus(ctx context.Context, orderNumber, status string, accrual money.Amount) error {
 	_, err := s.conn.ExecContext(ctx, `
 		UPDATE orders
 		SET status = $2, accrual = $3, updated_at = NOW()
@@ -241,38 +361,46 @@ func (s *StorageDB) UpdateOrderStatus(ctx context.Context, orderNumber, status s
 	return err
 }
 
-// Обновление баланса пользователя
-func (s *StorageDB) UpdateUserBalance(ctx context.Context, sum float64) error {
-	userID := ctx.Value(auth.UserIDKey)
-    
-    // Проверяем, существует ли запись с балансом для данного пользователя
-    var currentBalance float64
-    err := s.conn.QueryRowContext(ctx, `
-        SELECT current FROM balance WHERE user_id = $1
-    `, userID).Scan(&currentBalance)
-
-    if err != nil {
-        if err.Error() == sql.ErrNoRows.Error() {
-            // Если записи нет, то создаем новую запись с начальным значением баланса
-            _, err := s.conn.ExecContext(ctx, `
-                INSERT INTO balance (user_id, current, withdrawn) 
-                VALUES ($1, $2, $3)
-            `, userID, sum, 0.0)
-            if err != nil {
-                return fmt.Errorf("failed to insert balance record: %v", err)
-            }
-            return nil
-        }
-        // Другие ошибки
-        return fmt.Errorf("failed to check balance: %v", err)
-    }
-
-    // Если запись с балансом существует, выполняем обновление
-    _, err = s.conn.ExecContext(ctx, `
-        UPDATE balance
-		SET current = current + $2
-		WHERE user_id = $1
-    `, userID, sum)
-    
-    return err
-}
\ No newline at end of file
+// Фиксирует окончательный статус заказа и начисляет баллы его владельцу в одной транзакции.
+// Повторный вызов для уже обработанного заказа ничего не меняет. Возвращает true, если баллы начислены
+func (s *StorageDB) SettleOrder(ctx context.Context, orderNumber, status string, accrual money.Amount) (bool, error) {
+	if status != "PROCESSED" && status != "INVALID" {
+		return false, fmt.Errorf("%w: %s", storage.ErrOrderStatusNotFinal, status)
+	}
+
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Обновление блокирует строку заказа, поэтому параллельные вызовы выполняются по очереди
+	var userID int
+	err = tx.QueryRowContext(ctx, `
+		UPDATE orders
+		SET status = $2, accrual = $3, updated_at = NOW()
+		WHERE number = $1 AND status NOT IN ('PROCESSED', 'INVALID')
+		RETURNING user_id
+	`, orderNumber, status, accrual).Scan(&userID)
+	if err == sql.ErrNoRows {
+		// Заказ уже обработан ранее
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	credited := status == "PROCESSED" && accrual.IsPositive()
+	if credited {
+		if err = creditBalance(ctx, tx, userID, accrual, orderNumber); err != nil {
+			return false, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+
+	return credited, nil
+}
+
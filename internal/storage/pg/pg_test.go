@@ -0,0 +1,178 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/auth"
+	"github.com/dsemenov12/loyalty-gofermart/internal/money"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Начисляет пользователю баллы через обработку заказа
+func creditTestUser(t *testing.T, s *StorageDB, ctx context.Context, orderNumber, sum string) {
+	t.Helper()
+
+	_, err := s.SaveOrder(ctx, orderNumber)
+	require.NoError(t, err)
+	credited, err := s.SettleOrder(ctx, orderNumber, "PROCESSED", money.MustParse(sum))
+	require.NoError(t, err)
+	require.True(t, credited)
+}
+
+// Тестирование параллельных списаний: остаток проверяется под блокировкой строки баланса
+func TestStorageDB_WithdrawUserBalance_Concurrent(t *testing.T) {
+	s := newTestStorage(t)
+	ctx, _ := createTestUser(t, s, "user")
+	creditTestUser(t, s, ctx, "2377225624", "100")
+
+	const attempts = 10
+	var wg sync.WaitGroup
+	errs := make([]error, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = s.WithdrawUserBalance(ctx, fmt.Sprintf("withdraw-%d", i), money.MustParse("30"), "")
+		}(i)
+	}
+	wg.Wait()
+
+	var succeeded int
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.ErrorIs(t, err, storage.ErrInsufficientFunds)
+	}
+	assert.Equal(t, 3, succeeded)
+
+	balance, err := s.GetBalance(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, money.MustParse("10"), balance.Current)
+	assert.Equal(t, money.MustParse("90"), balance.Withdrawn)
+	assertLedgerReconciled(t, s)
+}
+
+// Тестирование повтора списания с ключом идемпотентности
+func TestStorageDB_WithdrawUserBalance_Idempotency(t *testing.T) {
+	s := newTestStorage(t)
+	ctx, _ := createTestUser(t, s, "user")
+	creditTestUser(t, s, ctx, "2377225624", "100")
+
+	withdrawn, err := s.WithdrawUserBalance(ctx, "12345678903", money.MustParse("40"), "key-1")
+	require.NoError(t, err)
+	assert.True(t, withdrawn)
+	// Повтор с тем же ключом не списывает баллы второй раз
+	withdrawn, err = s.WithdrawUserBalance(ctx, "12345678903", money.MustParse("40"), "key-1")
+	require.NoError(t, err)
+	assert.False(t, withdrawn)
+
+	_, err = s.WithdrawUserBalance(ctx, "12345678903", money.MustParse("50"), "key-1")
+	assert.ErrorIs(t, err, storage.ErrIdempotencyKeyReused)
+
+	withdrawals, err := s.GetUserWithdrawals(ctx)
+	require.NoError(t, err)
+	assert.Len(t, withdrawals, 1)
+
+	balance, err := s.GetBalance(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, money.MustParse("60"), balance.Current)
+	assertLedgerReconciled(t, s)
+}
+
+// Тестирование повторного списания по номеру заказа
+func TestStorageDB_WithdrawUserBalance_OrderAlreadyWithdrawn(t *testing.T) {
+	s := newTestStorage(t)
+	ctx, _ := createTestUser(t, s, "user")
+	creditTestUser(t, s, ctx, "2377225624", "100")
+	anotherCtx, _ := createTestUser(t, s, "another")
+	creditTestUser(t, s, anotherCtx, "12345678903", "100")
+
+	_, err := s.WithdrawUserBalance(ctx, "79927398713", money.MustParse("10"), "")
+	require.NoError(t, err)
+
+	_, err = s.WithdrawUserBalance(ctx, "79927398713", money.MustParse("10"), "")
+	assert.ErrorIs(t, err, storage.ErrOrderAlreadyWithdrawn)
+	_, err = s.WithdrawUserBalance(anotherCtx, "79927398713", money.MustParse("10"), "")
+	assert.ErrorIs(t, err, storage.ErrOrderAlreadyWithdrawn)
+
+	balance, err := s.GetBalance(anotherCtx)
+	require.NoError(t, err)
+	assert.Equal(t, money.MustParse("100"), balance.Current)
+}
+
+// Тестирование выборки заказов для опроса пачками с учётом отложенного срока опроса
+func TestStorageDB_GetPendingOrders_Schedule(t *testing.T) {
+	s := newTestStorage(t)
+	ctx, _ := createTestUser(t, s, "user")
+	for _, number := range []string{"2377225624", "12345678903", "79927398713"} {
+		_, err := s.SaveOrder(ctx, number)
+		require.NoError(t, err)
+	}
+
+	orders, err := s.GetPendingOrders(context.Background(), 2)
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	assert.Equal(t, "2377225624", orders[0].Number)
+	assert.Zero(t, orders[0].PollAttempts)
+
+	// Отложенный заказ не выбирается до наступления срока
+	require.NoError(t, s.ScheduleOrderPoll(context.Background(), "2377225624", time.Hour))
+	orders, err = s.GetPendingOrders(context.Background(), 10)
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	assert.Equal(t, "12345678903", orders[0].Number)
+	assert.Equal(t, "79927398713", orders[1].Number)
+
+	// Заказ с наступившим сроком выбирается со счётчиком опросов
+	require.NoError(t, s.ScheduleOrderPoll(context.Background(), "12345678903", 0))
+	orders, err = s.GetPendingOrders(context.Background(), 10)
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	assert.Equal(t, "79927398713", orders[0].Number)
+	assert.Equal(t, "12345678903", orders[1].Number)
+	assert.Equal(t, 1, orders[1].PollAttempts)
+}
+
+// Тестирование остановки миграции уникальности номеров списаний при дубликатах
+func TestMigration_WithdrawOrderNumberDuplicates(t *testing.T) {
+	conn, m := newTestDB(t)
+	require.NoError(t, m.Migrate(6))
+
+	_, err := conn.Exec(`INSERT INTO users (id, login, password) VALUES (1, 'user', 'hash')`)
+	require.NoError(t, err)
+	_, err = conn.Exec(`
+		INSERT INTO withdraw (user_id, order_number, sum)
+		VALUES (1, '2377225624', 10), (1, '2377225624', 20), (1, '12345678903', 30)
+	`)
+	require.NoError(t, err)
+
+	err = m.Up()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "withdraw has 1 order numbers used more than once: 2377225624")
+}
+
+// Тестирование методов текущего пользователя без идентификатора в контексте: до обращения к БД
+// возвращается ошибка хранилища
+func TestStorageDB_NoUserInContext(t *testing.T) {
+	s := &StorageDB{}
+	badCtx := context.WithValue(context.Background(), auth.UserIDKey, "not a number")
+
+	for _, ctx := range []context.Context{context.Background(), badCtx} {
+		_, err := s.SaveOrder(ctx, "2377225624")
+		assert.ErrorIs(t, err, storage.ErrNoUserInContext)
+		_, err = s.GetUserWithdrawals(ctx)
+		assert.ErrorIs(t, err, storage.ErrNoUserInContext)
+		_, err = s.GetOrdersByUser(ctx)
+		assert.ErrorIs(t, err, storage.ErrNoUserInContext)
+		_, err = s.WithdrawUserBalance(ctx, "2377225624", money.MustParse("10"), "")
+		assert.ErrorIs(t, err, storage.ErrNoUserInContext)
+	}
+}
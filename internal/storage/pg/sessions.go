@@ -0,0 +1,158 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage"
+)
+
+// Создаёт сессию вместе с её первым refresh-токеном
+func (s *StorageDB) CreateSession(ctx context.Context, session models.Session, refreshTokenHash string) error {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO sessions (id, user_id, created_at, expires_at)
+		VALUES ($1, $2, NOW(), $3)
+	`, session.ID, session.UserID, session.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (token_hash, session_id, created_at, expires_at)
+		VALUES ($1, $2, NOW(), $3)
+	`, refreshTokenHash, session.ID, session.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Обменивает refresh-токен на новый. Повторное предъявление уже использованного
+// токена означает, что он украден, поэтому сессия отзывается целиком
+func (s *StorageDB) RotateRefreshToken(ctx context.Context, refreshTokenHash, newRefreshTokenHash string, expiresAt time.Time) (*models.Session, error) {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Блокировка токена не даёт обменять его дважды параллельными запросами
+	var session models.Session
+	var tokenExpiresAt time.Time
+	var usedAt, revokedAt sql.NullTime
+	err = tx.QueryRowContext(ctx, `
+		SELECT s.id, s.user_id, u.role, s.revoked_at, t.expires_at, t.used_at
+		FROM refresh_tokens t
+		JOIN sessions s ON s.id = t.session_id
+		JOIN users u ON u.id = s.user_id
+		WHERE t.token_hash = $1
+		FOR UPDATE OF t
+	`, refreshTokenHash).Scan(&session.ID, &session.UserID, &session.Role, &revokedAt, &tokenExpiresAt, &usedAt)
+	if err == sql.ErrNoRows {
+		return nil, storage.ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if revokedAt.Valid || tokenExpiresAt.Before(time.Now()) {
+		return nil, storage.ErrSessionRevoked
+	}
+
+	if usedAt.Valid {
+		if err = revokeSession(ctx, tx, session.ID); err != nil {
+			return nil, err
+		}
+		if err = tx.Commit(); err != nil {
+			return nil, err
+		}
+		return nil, storage.ErrRefreshTokenReused
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE refresh_tokens SET used_at = NOW() WHERE token_hash = $1
+	`, refreshTokenHash)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (token_hash, session_id, created_at, expires_at)
+		VALUES ($1, $2, NOW(), $3)
+	`, newRefreshTokenHash, session.ID, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Срок сессии продлевается вместе с refresh-токеном
+	_, err = tx.ExecContext(ctx, `
+		UPDATE sessions SET expires_at = $2 WHERE id = $1
+	`, session.ID, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	session.ExpiresAt = expiresAt
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// Отзывает сессию. Повторный отзыв не является ошибкой
+func (s *StorageDB) RevokeSession(ctx context.Context, sessionID string) error {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = revokeSession(ctx, tx, sessionID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Проверяет, отозвана ли сессия. Неизвестная или истёкшая сессия считается отозванной
+func (s *StorageDB) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	var active bool
+	err := s.conn.QueryRowContext(ctx, `
+		SELECT revoked_at IS NULL AND expires_at > NOW()
+		FROM sessions
+		WHERE id = $1
+	`, sessionID).Scan(&active)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return !active, nil
+}
+
+func revokeSession(ctx context.Context, tx *sql.Tx, sessionID string) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL
+	`, sessionID)
+	return err
+}
+
+// Отзывает все сессии пользователя
+func revokeUserSessions(ctx context.Context, tx *sql.Tx, userID int) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID)
+	return err
+}
@@ -0,0 +1,218 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage"
+)
+
+// Настройки двухфакторной аутентификации пользователя
+func (s *StorageDB) GetTOTP(ctx context.Context, userID int) (*models.TOTP, error) {
+	var totp models.TOTP
+	var secret sql.NullString
+	var lastStep sql.NullInt64
+	err := s.conn.QueryRowContext(ctx, `
+		SELECT totp_secret, totp_enabled, totp_last_step
+		FROM users
+		WHERE id = $1
+	`, userID).Scan(&secret, &totp.Enabled, &lastStep)
+	if err == sql.ErrNoRows {
+		return nil, storage.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	totp.Secret = secret.String
+	totp.LastStep = lastStep.Int64
+	return &totp, nil
+}
+
+// Сохраняет секрет, ожидающий подтверждения кодом. Повторная настройка заменяет секрет,
+// пока двухфакторная аутентификация не включена
+func (s *StorageDB) SetTOTPSecret(ctx context.Context, userID int, secret string) error {
+	result, err := s.conn.ExecContext(ctx, `
+		UPDATE users
+		SET totp_secret = $2, totp_last_step = NULL
+		WHERE id = $1 AND NOT totp_enabled
+	`, userID, secret)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return storage.ErrTOTPAlreadyEnabled
+	}
+	return nil
+}
+
+// Включает двухфакторную аутентификацию и заменяет коды восстановления.
+// step — шаг кода, которым подтверждено включение, он не может быть использован повторно
+func (s *StorageDB) EnableTOTP(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) error {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE users
+		SET totp_enabled = TRUE, totp_last_step = $2
+		WHERE id = $1 AND totp_secret IS NOT NULL AND NOT totp_enabled
+	`, userID, step)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return storage.ErrTOTPAlreadyEnabled
+	}
+
+	if err = replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Отключает двухфакторную аутентификацию и удаляет коды восстановления
+func (s *StorageDB) DisableTOTP(ctx context.Context, userID int) error {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE users
+		SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = NULL
+		WHERE id = $1
+	`, userID)
+	if err != nil {
+		return err
+	}
+
+	if err = replaceRecoveryCodes(ctx, tx, userID, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Отмечает шаг кода использованным. Код того же или более раннего шага отклоняется
+func (s *StorageDB) UseTOTPStep(ctx context.Context, userID int, step int64) error {
+	result, err := s.conn.ExecContext(ctx, `
+		UPDATE users
+		SET totp_last_step = $2
+		WHERE id = $1 AND (totp_last_step IS NULL OR totp_last_step < $2)
+	`, userID, step)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return storage.ErrTOTPCodeReused
+	}
+	return nil
+}
+
+// Гасит код восстановления
+func (s *StorageDB) UseRecoveryCode(ctx context.Context, userID int, codeHash string) error {
+	result, err := s.conn.ExecContext(ctx, `
+		UPDATE recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, codeHash)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return storage.ErrRecoveryCodeInvalid
+	}
+	return nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int, codeHashes []string) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, codeHash := range codeHashes {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO recovery_codes (user_id, code_hash, created_at)
+			VALUES ($1, $2, NOW())
+		`, userID, codeHash)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Сохраняет выданный токен второго шага входа
+func (s *StorageDB) CreateTwoFactorChallenge(ctx context.Context, challengeID string, userID int, ttl time.Duration) error {
+	_, err := s.conn.ExecContext(ctx, `
+		INSERT INTO two_factor_challenges (id, user_id, expires_at)
+		VALUES ($1, $2, NOW() + make_interval(secs => $3))
+	`, challengeID, userID, ttl.Seconds())
+	return err
+}
+
+// Проверяет, что токен второго шага выдан пользователю, не погашен и не истёк
+func (s *StorageDB) CheckTwoFactorChallenge(ctx context.Context, challengeID string, userID int) error {
+	var exists bool
+	err := s.conn.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM two_factor_challenges
+			WHERE id = $1 AND user_id = $2 AND used_at IS NULL AND expires_at > NOW()
+		)
+	`, challengeID, userID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return storage.ErrChallengeInvalid
+	}
+	return nil
+}
+
+// Погашает токен второго шага. Из параллельных запросов с одним токеном успешен только один
+func (s *StorageDB) ConsumeTwoFactorChallenge(ctx context.Context, challengeID string, userID int) error {
+	result, err := s.conn.ExecContext(ctx, `
+		UPDATE two_factor_challenges
+		SET used_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND used_at IS NULL AND expires_at > NOW()
+	`, challengeID, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return storage.ErrChallengeInvalid
+	}
+	return nil
+}
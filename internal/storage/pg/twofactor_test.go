@@ -0,0 +1,34 @@
+package pg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тестирование одноразового токена второго шага входа
+func TestStorageDB_TwoFactorChallenge(t *testing.T) {
+	s := newTestStorage(t)
+	_, userID := createTestUser(t, s, "user")
+	_, anotherID := createTestUser(t, s, "another")
+	ctx := context.Background()
+
+	require.NoError(t, s.CreateTwoFactorChallenge(ctx, "challenge", userID, time.Minute))
+	require.NoError(t, s.CheckTwoFactorChallenge(ctx, "challenge", userID))
+	assert.ErrorIs(t, s.CheckTwoFactorChallenge(ctx, "challenge", anotherID), storage.ErrChallengeInvalid)
+	assert.ErrorIs(t, s.CheckTwoFactorChallenge(ctx, "unknown", userID), storage.ErrChallengeInvalid)
+
+	// Токен погашается один раз
+	require.NoError(t, s.ConsumeTwoFactorChallenge(ctx, "challenge", userID))
+	assert.ErrorIs(t, s.ConsumeTwoFactorChallenge(ctx, "challenge", userID), storage.ErrChallengeInvalid)
+	assert.ErrorIs(t, s.CheckTwoFactorChallenge(ctx, "challenge", userID), storage.ErrChallengeInvalid)
+
+	// Истёкший токен не принимается
+	require.NoError(t, s.CreateTwoFactorChallenge(ctx, "expired", userID, -time.Minute))
+	assert.ErrorIs(t, s.CheckTwoFactorChallenge(ctx, "expired", userID), storage.ErrChallengeInvalid)
+	assert.ErrorIs(t, s.ConsumeTwoFactorChallenge(ctx, "expired", userID), storage.ErrChallengeInvalid)
+}
@@ -2,18 +2,51 @@ package storage
 
 import (
 	"context"
+	"time"
 
 	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/money"
 )
 
 type Storage interface {
 	CreateUser(ctx context.Context, login, hashedPassword string) error
 	GetUserByLogin(ctx context.Context, login string) (*models.User, error)
+	GetUserByID(ctx context.Context, userID int) (*models.User, error)
+	DeleteUser(ctx context.Context, userID int, balancePolicy string) error
+	UpdatePassword(ctx context.Context, userID int, hashedPassword string) error
+	CreatePasswordResetToken(ctx context.Context, userID int, tokenHash string, ttl time.Duration) error
+	GetPasswordResetUser(ctx context.Context, tokenHash string) (*models.User, error)
+	ResetPassword(ctx context.Context, tokenHash, hashedPassword string) error
 	SaveOrder(ctx context.Context, orderNumber string) (bool, error)
 	GetOrdersByUser(ctx context.Context) ([]models.Order, error)
+	GetOrdersByUserID(ctx context.Context, userID int) ([]models.Order, error)
+	GetOrderByNumber(ctx context.Context, orderNumber string) (*models.Order, error)
+	GetPendingOrders(ctx context.Context, limit int) ([]models.Order, error)
+	ScheduleOrderPoll(ctx context.Context, orderNumber string, delay time.Duration) error
 	GetBalance(ctx context.Context) (*models.Balance, error)
-	WithdrawUserBalance(ctx context.Context, orderNumber string, amount float64) error
+	GetBalanceByUserID(ctx context.Context, userID int) (*models.Balance, error)
+	AdjustBalance(ctx context.Context, adjustment models.BalanceAdjustment) (*models.BalanceAdjustment, error)
+	GetBalanceAdjustments(ctx context.Context, userID int) ([]models.BalanceAdjustment, error)
+	GetBalanceHistory(ctx context.Context) ([]models.Posting, error)
+	WithdrawUserBalance(ctx context.Context, orderNumber string, amount money.Amount, idempotencyKey string) (bool, error)
 	GetUserWithdrawals(ctx context.Context) ([]models.Withdrawal, error)
-	UpdateOrderStatus(ctx context.Context, orderNumber, status string, accrual float64) error
-	UpdateUserBalance(ctx context.Context, sum float64) error
+	UpdateOrderStatus(ctx context.Context, orderNumber, status string, accrual money.Amount) error
+	SettleOrder(ctx context.Context, orderNumber, status string, accrual money.Amount) (bool, error)
+	CreateSession(ctx context.Context, session models.Session, refreshTokenHash string) error
+	RotateRefreshToken(ctx context.Context, refreshTokenHash, newRefreshTokenHash string, expiresAt time.Time) (*models.Session, error)
+	RevokeSession(ctx context.Context, sessionID string) error
+	IsSessionRevoked(ctx context.Context, sessionID string) (bool, error)
+	GetLoginLockout(ctx context.Context, keys ...string) (time.Duration, error)
+	RecordLoginFailure(ctx context.Context, key string, window time.Duration) (int, error)
+	LockLogin(ctx context.Context, key string, d time.Duration) error
+	ResetLoginFailures(ctx context.Context, key string) error
+	GetTOTP(ctx context.Context, userID int) (*models.TOTP, error)
+	SetTOTPSecret(ctx context.Context, userID int, secret string) error
+	EnableTOTP(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) error
+	DisableTOTP(ctx context.Context, userID int) error
+	UseTOTPStep(ctx context.Context, userID int, step int64) error
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) error
+	CreateTwoFactorChallenge(ctx context.Context, challengeID string, userID int, ttl time.Duration) error
+	CheckTwoFactorChallenge(ctx context.Context, challengeID string, userID int) error
+	ConsumeTwoFactorChallenge(ctx context.Context, challengeID string, userID int) error
 }
\ No newline at end of file
@@ -0,0 +1,275 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/models"
+	"github.com/dsemenov12/loyalty-gofermart/internal/money"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Хранилище, открывающее дочерний спан на каждый вызов метода
+type Storage struct {
+	next storage.Storage
+}
+
+var _ storage.Storage = (*Storage)(nil)
+
+func NewStorage(next storage.Storage) *Storage {
+	return &Storage{next: next}
+}
+
+func start(ctx context.Context, method string) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, "storage."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationName(method)),
+	)
+}
+
+func finish(span trace.Span, err *error) {
+	RecordError(span, *err)
+	span.End()
+}
+
+func (s *Storage) CreateUser(ctx context.Context, login, hashedPassword string) (err error) {
+	ctx, span := start(ctx, "CreateUser")
+	defer finish(span, &err)
+	return s.next.CreateUser(ctx, login, hashedPassword)
+}
+
+func (s *Storage) GetUserByLogin(ctx context.Context, login string) (_ *models.User, err error) {
+	ctx, span := start(ctx, "GetUserByLogin")
+	defer finish(span, &err)
+	return s.next.GetUserByLogin(ctx, login)
+}
+
+func (s *Storage) GetUserByID(ctx context.Context, userID int) (_ *models.User, err error) {
+	ctx, span := start(ctx, "GetUserByID")
+	defer finish(span, &err)
+	return s.next.GetUserByID(ctx, userID)
+}
+
+func (s *Storage) DeleteUser(ctx context.Context, userID int, balancePolicy string) (err error) {
+	ctx, span := start(ctx, "DeleteUser")
+	defer finish(span, &err)
+	return s.next.DeleteUser(ctx, userID, balancePolicy)
+}
+
+func (s *Storage) UpdatePassword(ctx context.Context, userID int, hashedPassword string) (err error) {
+	ctx, span := start(ctx, "UpdatePassword")
+	defer finish(span, &err)
+	return s.next.UpdatePassword(ctx, userID, hashedPassword)
+}
+
+func (s *Storage) CreatePasswordResetToken(ctx context.Context, userID int, tokenHash string, ttl time.Duration) (err error) {
+	ctx, span := start(ctx, "CreatePasswordResetToken")
+	defer finish(span, &err)
+	return s.next.CreatePasswordResetToken(ctx, userID, tokenHash, ttl)
+}
+
+func (s *Storage) GetPasswordResetUser(ctx context.Context, tokenHash string) (_ *models.User, err error) {
+	ctx, span := start(ctx, "GetPasswordResetUser")
+	defer finish(span, &err)
+	return s.next.GetPasswordResetUser(ctx, tokenHash)
+}
+
+func (s *Storage) ResetPassword(ctx context.Context, tokenHash, hashedPassword string) (err error) {
+	ctx, span := start(ctx, "ResetPassword")
+	defer finish(span, &err)
+	return s.next.ResetPassword(ctx, tokenHash, hashedPassword)
+}
+
+func (s *Storage) SaveOrder(ctx context.Context, orderNumber string) (_ bool, err error) {
+	ctx, span := start(ctx, "SaveOrder")
+	defer finish(span, &err)
+	return s.next.SaveOrder(ctx, orderNumber)
+}
+
+func (s *Storage) GetOrdersByUser(ctx context.Context) (_ []models.Order, err error) {
+	ctx, span := start(ctx, "GetOrdersByUser")
+	defer finish(span, &err)
+	return s.next.GetOrdersByUser(ctx)
+}
+
+func (s *Storage) GetOrdersByUserID(ctx context.Context, userID int) (_ []models.Order, err error) {
+	ctx, span := start(ctx, "GetOrdersByUserID")
+	defer finish(span, &err)
+	return s.next.GetOrdersByUserID(ctx, userID)
+}
+
+func (s *Storage) GetOrderByNumber(ctx context.Context, orderNumber string) (_ *models.Order, err error) {
+	ctx, span := start(ctx, "GetOrderByNumber")
+	defer finish(span, &err)
+	return s.next.GetOrderByNumber(ctx, orderNumber)
+}
+
+func (s *Storage) GetPendingOrders(ctx context.Context, limit int) (_ []models.Order, err error) {
+	ctx, span := start(ctx, "GetPendingOrders")
+	defer finish(span, &err)
+	return s.next.GetPendingOrders(ctx, limit)
+}
+
+func (s *Storage) ScheduleOrderPoll(ctx context.Context, orderNumber string, delay time.Duration) (err error) {
+	ctx, span := start(ctx, "ScheduleOrderPoll")
+	defer finish(span, &err)
+	return s.next.ScheduleOrderPoll(ctx, orderNumber, delay)
+}
+
+func (s *Storage) GetBalance(ctx context.Context) (_ *models.Balance, err error) {
+	ctx, span := start(ctx, "GetBalance")
+	defer finish(span, &err)
+	return s.next.GetBalance(ctx)
+}
+
+func (s *Storage) GetBalanceByUserID(ctx context.Context, userID int) (_ *models.Balance, err error) {
+	ctx, span := start(ctx, "GetBalanceByUserID")
+	defer finish(span, &err)
+	return s.next.GetBalanceByUserID(ctx, userID)
+}
+
+func (s *Storage) AdjustBalance(ctx context.Context, adjustment models.BalanceAdjustment) (_ *models.BalanceAdjustment, err error) {
+	ctx, span := start(ctx, "AdjustBalance")
+	defer finish(span, &err)
+	return s.next.AdjustBalance(ctx, adjustment)
+}
+
+func (s *Storage) GetBalanceAdjustments(ctx context.Context, userID int) (_ []models.BalanceAdjustment, err error) {
+	ctx, span := start(ctx, "GetBalanceAdjustments")
+	defer finish(span, &err)
+	return s.next.GetBalanceAdjustments(ctx, userID)
+}
+
+func (s *Storage) GetBalanceHistory(ctx context.Context) (_ []models.Posting, err error) {
+	ctx, span := start(ctx, "GetBalanceHistory")
+	defer finish(span, &err)
+	return s.next.GetBalanceHistory(ctx)
+}
+
+func (s *Storage) WithdrawUserBalance(ctx context.Context, orderNumber string, amount money.Amount, idempotencyKey string) (_ bool, err error) {
+	ctx, span := start(ctx, "WithdrawUserBalance")
+	defer finish(span, &err)
+	return s.next.WithdrawUserBalance(ctx, orderNumber, amount, idempotencyKey)
+}
+
+func (s *Storage) GetUserWithdrawals(ctx context.Context) (_ []models.Withdrawal, err error) {
+	ctx, span := start(ctx, "GetUserWithdrawals")
+	defer finish(span, &err)
+	return s.next.GetUserWithdrawals(ctx)
+}
+
+func (s *Storage) UpdateOrderStatus(ctx context.Context, orderNumber, status string, accrual money.Amount) (err error) {
+	ctx, span := start(ctx, "UpdateOrderStatus")
+	defer finish(span, &err)
+	return s.next.UpdateOrderStatus(ctx, orderNumber, status, accrual)
+}
+
+func (s *Storage) SettleOrder(ctx context.Context, orderNumber, status string, accrual money.Amount) (_ bool, err error) {
+	ctx, span := start(ctx, "SettleOrder")
+	defer finish(span, &err)
+	return s.next.SettleOrder(ctx, orderNumber, status, accrual)
+}
+
+func (s *Storage) CreateSession(ctx context.Context, session models.Session, refreshTokenHash string) (err error) {
+	ctx, span := start(ctx, "CreateSession")
+	defer finish(span, &err)
+	return s.next.CreateSession(ctx, session, refreshTokenHash)
+}
+
+func (s *Storage) RotateRefreshToken(ctx context.Context, refreshTokenHash, newRefreshTokenHash string, expiresAt time.Time) (_ *models.Session, err error) {
+	ctx, span := start(ctx, "RotateRefreshToken")
+	defer finish(span, &err)
+	return s.next.RotateRefreshToken(ctx, refreshTokenHash, newRefreshTokenHash, expiresAt)
+}
+
+func (s *Storage) RevokeSession(ctx context.Context, sessionID string) (err error) {
+	ctx, span := start(ctx, "RevokeSession")
+	defer finish(span, &err)
+	return s.next.RevokeSession(ctx, sessionID)
+}
+
+func (s *Storage) IsSessionRevoked(ctx context.Context, sessionID string) (_ bool, err error) {
+	ctx, span := start(ctx, "IsSessionRevoked")
+	defer finish(span, &err)
+	return s.next.IsSessionRevoked(ctx, sessionID)
+}
+
+func (s *Storage) GetLoginLockout(ctx context.Context, keys ...string) (_ time.Duration, err error) {
+	ctx, span := start(ctx, "GetLoginLockout")
+	defer finish(span, &err)
+	return s.next.GetLoginLockout(ctx, keys...)
+}
+
+func (s *Storage) RecordLoginFailure(ctx context.Context, key string, window time.Duration) (_ int, err error) {
+	ctx, span := start(ctx, "RecordLoginFailure")
+	defer finish(span, &err)
+	return s.next.RecordLoginFailure(ctx, key, window)
+}
+
+func (s *Storage) LockLogin(ctx context.Context, key string, d time.Duration) (err error) {
+	ctx, span := start(ctx, "LockLogin")
+	defer finish(span, &err)
+	return s.next.LockLogin(ctx, key, d)
+}
+
+func (s *Storage) ResetLoginFailures(ctx context.Context, key string) (err error) {
+	ctx, span := start(ctx, "ResetLoginFailures")
+	defer finish(span, &err)
+	return s.next.ResetLoginFailures(ctx, key)
+}
+
+func (s *Storage) GetTOTP(ctx context.Context, userID int) (_ *models.TOTP, err error) {
+	ctx, span := start(ctx, "GetTOTP")
+	defer finish(span, &err)
+	return s.next.GetTOTP(ctx, userID)
+}
+
+func (s *Storage) SetTOTPSecret(ctx context.Context, userID int, secret string) (err error) {
+	ctx, span := start(ctx, "SetTOTPSecret")
+	defer finish(span, &err)
+	return s.next.SetTOTPSecret(ctx, userID, secret)
+}
+
+func (s *Storage) EnableTOTP(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) (err error) {
+	ctx, span := start(ctx, "EnableTOTP")
+	defer finish(span, &err)
+	return s.next.EnableTOTP(ctx, userID, step, recoveryCodeHashes)
+}
+
+func (s *Storage) DisableTOTP(ctx context.Context, userID int) (err error) {
+	ctx, span := start(ctx, "DisableTOTP")
+	defer finish(span, &err)
+	return s.next.DisableTOTP(ctx, userID)
+}
+
+func (s *Storage) UseTOTPStep(ctx context.Context, userID int, step int64) (err error) {
+	ctx, span := start(ctx, "UseTOTPStep")
+	defer finish(span, &err)
+	return s.next.UseTOTPStep(ctx, userID, step)
+}
+
+func (s *Storage) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (err error) {
+	ctx, span := start(ctx, "UseRecoveryCode")
+	defer finish(span, &err)
+	return s.next.UseRecoveryCode(ctx, userID, codeHash)
+}
+
+func (s *Storage) CreateTwoFactorChallenge(ctx context.Context, challengeID string, userID int, ttl time.Duration) (err error) {
+	ctx, span := start(ctx, "CreateTwoFactorChallenge")
+	defer finish(span, &err)
+	return s.next.CreateTwoFactorChallenge(ctx, challengeID, userID, ttl)
+}
+
+func (s *Storage) CheckTwoFactorChallenge(ctx context.Context, challengeID string, userID int) (err error) {
+	ctx, span := start(ctx, "CheckTwoFactorChallenge")
+	defer finish(span, &err)
+	return s.next.CheckTwoFactorChallenge(ctx, challengeID, userID)
+}
+
+func (s *Storage) ConsumeTwoFactorChallenge(ctx context.Context, challengeID string, userID int) (err error) {
+	ctx, span := start(ctx, "ConsumeTwoFactorChallenge")
+	defer finish(span, &err)
+	return s.next.ConsumeTwoFactorChallenge(ctx, challengeID, userID)
+}
@@ -0,0 +1,95 @@
+// Пакет tracing настраивает трассировку OpenTelemetry.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Экспортёры спанов
+const (
+	ExporterNone   = "none"   // спаны не собираются, контекст трассы только передаётся дальше
+	ExporterStdout = "stdout" // спаны печатаются в стандартный вывод, для локального запуска
+	ExporterOTLP   = "otlp"   // спаны отправляются по OTLP/HTTP, адрес задаётся переменными OTEL_EXPORTER_OTLP_*
+)
+
+// Имя сервиса в трассах, если не задано через OTEL_SERVICE_NAME
+const serviceName = "gophermart"
+
+const instrumentationName = "github.com/dsemenov12/loyalty-gofermart"
+
+// Трейсер для спанов, открываемых вручную
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Устанавливает глобальный провайдер трассировки и пропагатор W3C Trace Context.
+// Возвращает функцию, которая выгружает накопленные спаны при остановке сервиса
+func Initialize(ctx context.Context, exporter string, sampleRatio float64) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var (
+		spanExporter sdktrace.SpanExporter
+		err          error
+	)
+	switch exporter {
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		spanExporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Решение о записи принимает сервис, начавший трассу
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Идентификатор трассы для записи в лог либо пустая строка, если запрос не трассируется
+func TraceID(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasTraceID() {
+		return ""
+	}
+	return spanContext.TraceID().String()
+}
+
+// Отмечает спан как завершившийся ошибкой
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
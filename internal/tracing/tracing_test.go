@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage"
+	"github.com/dsemenov12/loyalty-gofermart/internal/storage/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// Тестирование выбора экспортёра
+func TestInitialize(t *testing.T) {
+	shutdown, err := Initialize(context.Background(), ExporterNone, 1)
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	shutdown, err = Initialize(context.Background(), ExporterStdout, 1)
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	_, err = Initialize(context.Background(), "jaeger", 1)
+	assert.EqualError(t, err, `unknown tracing exporter "jaeger"`)
+}
+
+// Тестирование спанов вызовов хранилища
+func TestStorage_spans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mocks.NewMockStorage(ctrl)
+	mockStorage.EXPECT().SaveOrder(gomock.Any(), "2377225624").Return(true, nil)
+	mockStorage.EXPECT().SaveOrder(gomock.Any(), "12345678903").Return(false, storage.ErrOrderExistsForAnother)
+
+	ctx, parent := Tracer().Start(context.Background(), "POST /api/user/orders")
+	s := NewStorage(mockStorage)
+
+	created, err := s.SaveOrder(ctx, "2377225624")
+	assert.NoError(t, err)
+	assert.True(t, created)
+
+	_, err = s.SaveOrder(ctx, "12345678903")
+	assert.ErrorIs(t, err, storage.ErrOrderExistsForAnother)
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	for _, span := range spans[:2] {
+		assert.Equal(t, "storage.SaveOrder", span.Name())
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+	}
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+	assert.Equal(t, storage.ErrOrderExistsForAnother.Error(), spans[1].Status().Description)
+}
+
+// Тестирование идентификатора трассы для логов
+func TestTraceID(t *testing.T) {
+	assert.Empty(t, TraceID(context.Background()))
+
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "request")
+	defer span.End()
+
+	assert.Equal(t, span.SpanContext().TraceID().String(), TraceID(ctx))
+}
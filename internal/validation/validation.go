@@ -0,0 +1,138 @@
+// Пакет validation проверяет учётные данные по настраиваемой политике.
+package validation
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/problem"
+)
+
+// Ограничения, не зависящие от настроек
+const (
+	LoginMaxLength   = 255 // размер столбца users.login
+	PasswordMaxBytes = 72  // bcrypt учитывает только первые 72 байта пароля
+)
+
+// Политика проверки логина и пароля
+type Policy struct {
+	LoginMinLength      int
+	LoginPattern        *regexp.Regexp
+	PasswordMinLength   int
+	PasswordCharClasses int // минимальное число классов символов: строчные, прописные, цифры, прочие
+	// Утекшие пароли в нижнем регистре
+	Breached map[string]struct{}
+}
+
+// Политика по умолчанию: непустой логин из латиницы, цифр и символов ._@+-, пароль
+// не короче 8 символов любого состава. Список утекших паролей не задан
+var DefaultPolicy = Policy{
+	LoginMinLength:      1,
+	LoginPattern:        regexp.MustCompile(`^[A-Za-z0-9._@+-]+$`),
+	PasswordMinLength:   8,
+	PasswordCharClasses: 1,
+}
+
+var policy = DefaultPolicy
+
+func Initialize(p Policy) {
+	policy = p
+}
+
+// Загружает список утекших паролей: по одному паролю в строке, пустые строки пропускаются
+func LoadBreachedPasswords(path string) (map[string]struct{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	breached := make(map[string]struct{})
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if password := strings.TrimSpace(scanner.Text()); password != "" {
+			breached[strings.ToLower(password)] = struct{}{}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read breached passwords: %w", err)
+	}
+
+	return breached, nil
+}
+
+// Проверка логина и пароля при регистрации
+func ValidateRegistration(login, password string) []problem.FieldError {
+	fieldErrors := ValidateLogin(login)
+	return append(fieldErrors, ValidatePassword(login, password)...)
+}
+
+// Проверка формата логина
+func ValidateLogin(login string) []problem.FieldError {
+	length := utf8.RuneCountInString(login)
+	switch {
+	case length < policy.LoginMinLength:
+		return []problem.FieldError{fieldError("login", problem.FieldTooShort, "Login must be at least %d characters long", policy.LoginMinLength)}
+	case length > LoginMaxLength:
+		return []problem.FieldError{fieldError("login", problem.FieldTooLong, "Login must be at most %d characters long", LoginMaxLength)}
+	case policy.LoginPattern != nil && !policy.LoginPattern.MatchString(login):
+		return []problem.FieldError{fieldError("login", problem.FieldInvalid, "Login contains forbidden characters")}
+	}
+	return nil
+}
+
+// Проверка стойкости пароля. Логин нужен, чтобы отклонить пароль, совпадающий с ним
+func ValidatePassword(login, password string) []problem.FieldError {
+	switch {
+	case utf8.RuneCountInString(password) < policy.PasswordMinLength:
+		return []problem.FieldError{fieldError("password", problem.FieldTooShort, "Password must be at least %d characters long", policy.PasswordMinLength)}
+	case len(password) > PasswordMaxBytes:
+		return []problem.FieldError{fieldError("password", problem.FieldTooLong, "Password must be at most %d bytes long", PasswordMaxBytes)}
+	case charClasses(password) < policy.PasswordCharClasses:
+		return []problem.FieldError{fieldError("password", problem.FieldTooWeak, "Password must contain at least %d of: lowercase letters, uppercase letters, digits, other characters", policy.PasswordCharClasses)}
+	case login != "" && strings.EqualFold(login, password):
+		return []problem.FieldError{fieldError("password", problem.FieldTooWeak, "Password must not match the login")}
+	case isBreached(password):
+		return []problem.FieldError{fieldError("password", problem.FieldBreached, "Password appears in a list of breached passwords")}
+	}
+	return nil
+}
+
+// Число классов символов в пароле
+func charClasses(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+
+	count := 0
+	for _, present := range []bool{lower, upper, digit, other} {
+		if present {
+			count++
+		}
+	}
+	return count
+}
+
+func isBreached(password string) bool {
+	_, ok := policy.Breached[strings.ToLower(password)]
+	return ok
+}
+
+func fieldError(field, code, format string, args ...interface{}) problem.FieldError {
+	return problem.FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)}
+}
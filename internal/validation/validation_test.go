@@ -0,0 +1,71 @@
+package validation
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/dsemenov12/loyalty-gofermart/internal/problem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тестирование проверки логина и пароля
+func TestValidateRegistration(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "breached.txt")
+	require.NoError(t, os.WriteFile(path, []byte("Qwerty123!\n\npassword\n"), 0o600))
+	breached, err := LoadBreachedPasswords(path)
+	require.NoError(t, err)
+
+	Initialize(Policy{
+		LoginMinLength:      3,
+		LoginPattern:        regexp.MustCompile(`^[a-z0-9]+$`),
+		PasswordMinLength:   8,
+		PasswordCharClasses: 3,
+		Breached:            breached,
+	})
+	defer Initialize(DefaultPolicy)
+
+	tests := []struct {
+		name      string
+		login     string
+		password  string
+		wantField string
+		wantCode  string
+	}{
+		{name: "valid", login: "user", password: "Secr3t-pass"},
+		{name: "short login", login: "ab", password: "Secr3t-pass", wantField: "login", wantCode: problem.FieldTooShort},
+		{name: "long login", login: strings.Repeat("a", LoginMaxLength+1), password: "Secr3t-pass", wantField: "login", wantCode: problem.FieldTooLong},
+		{name: "login format", login: "User!", password: "Secr3t-pass", wantField: "login", wantCode: problem.FieldInvalid},
+		{name: "short password", login: "user", password: "Ab1!", wantField: "password", wantCode: problem.FieldTooShort},
+		{name: "password over bcrypt limit", login: "user", password: strings.Repeat("Ab1!", 19), wantField: "password", wantCode: problem.FieldTooLong},
+		{name: "multibyte password over bcrypt limit", login: "user", password: strings.Repeat("Пароль1!", 6), wantField: "password", wantCode: problem.FieldTooLong},
+		{name: "too few character classes", login: "user", password: "onlylowercase", wantField: "password", wantCode: problem.FieldTooWeak},
+		{name: "password equals login", login: "user12abc", password: "USER12abc", wantField: "password", wantCode: problem.FieldTooWeak},
+		{name: "breached password", login: "user", password: "qwerty123!", wantField: "password", wantCode: problem.FieldBreached},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fieldErrors := ValidateRegistration(tt.login, tt.password)
+			if tt.wantCode == "" {
+				assert.Empty(t, fieldErrors)
+				return
+			}
+			if assert.Len(t, fieldErrors, 1) {
+				assert.Equal(t, tt.wantField, fieldErrors[0].Field)
+				assert.Equal(t, tt.wantCode, fieldErrors[0].Code)
+			}
+		})
+	}
+}
+
+// Тестирование политики по умолчанию. Политика проверяется только при регистрации и смене пароля,
+// поэтому вход с прежними короткими паролями продолжает работать
+func TestDefaultPolicy(t *testing.T) {
+	assert.Empty(t, ValidateRegistration("user@example.com", "12345678"))
+	assert.NotEmpty(t, ValidateRegistration("user", "1234567"))
+}